@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/hymns/go-artisan/config"
+	"github.com/hymns/go-artisan/statement"
+	"github.com/joho/godotenv"
+)
+
+// sources records where each environment variable came from. Precedence is
+// flags, then the process environment, then .env, then the config file.
+var sources = make(map[string]string)
+
+var configPath string
+
+// alternateKeys are the older names still read by getEnvWithFallback. A
+// config value is skipped when either name is already set.
+var alternateKeys = map[string]string{
+	"DB_DATABASE": "DB_NAME",
+	"DB_USERNAME": "DB_USER",
+	"DB_PASSWORD": "DB_PASS",
+}
+
+func loadEnvironment() {
+	for _, pair := range os.Environ() {
+		if key, _, ok := strings.Cut(pair, "="); ok {
+			sources[key] = "environment"
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		color.Yellow("Warning: Could not get current directory")
+		return
+	}
+
+	configPath = config.Find(cwd)
+	loadEnvFile(cwd)
+
+	if configPath != "" {
+		loadConfigFile(configPath)
+	}
+}
+
+func loadEnvFile(cwd string) {
+	envPath := filepath.Join(cwd, ".env")
+	if _, err := os.Stat(envPath); err != nil {
+		if configPath == "" {
+			color.Yellow("Warning: .env file not found in current directory")
+		}
+		return
+	}
+
+	values, err := godotenv.Read(envPath)
+	if err != nil {
+		color.Yellow("Warning: Failed to load .env file")
+		return
+	}
+
+	// Like godotenv.Load, never override the process environment
+	for key, value := range values {
+		if _, exists := os.LookupEnv(key); exists {
+			continue
+		}
+		os.Setenv(key, value)
+		sources[key] = ".env"
+	}
+}
+
+func loadConfigFile(path string) {
+	file, err := config.Load(path)
+	if err != nil {
+		color.Yellow("Warning: %v", err)
+		return
+	}
+
+	for _, setting := range file.Settings() {
+		if isSet(setting.Key) {
+			continue
+		}
+		os.Setenv(setting.Key, setting.Value)
+		sources[setting.Key] = filepath.Base(path)
+	}
+}
+
+func isSet(key string) bool {
+	if _, exists := os.LookupEnv(key); exists {
+		return true
+	}
+	for primary, alternate := range alternateKeys {
+		if strings.HasSuffix(key, primary) {
+			_, exists := os.LookupEnv(strings.TrimSuffix(key, primary) + alternate)
+			return exists
+		}
+	}
+	return false
+}
+
+func handleConfigShow(database string) {
+	color.Cyan("\nConfiguration:\n")
+
+	file := "(none)"
+	if configPath != "" {
+		file = configPath
+	}
+	fmt.Printf("  %-18s %s\n", "config file", file)
+	printSetting("APP_ENV", getEnv("APP_ENV", ""), "APP_ENV")
+	printSetting("STUBS_PATH", getEnv("STUBS_PATH", "./database/stubs"), "STUBS_PATH")
+
+	for _, conn := range loadConnections() {
+		if database != "" && conn.Name != database {
+			continue
+		}
+
+		title := conn.Name
+		if conn.Default {
+			title += " (default)"
+		}
+		color.Cyan("\nConnection: %s\n", title)
+
+		if conn.err != nil {
+			color.Red("  ✗ %v", conn.err)
+		}
+
+		// Fields taken from the URL show the URL as their source
+		source := func(field string) string {
+			if conn.URL != "" && conn.keys[field] == "" {
+				return conn.keys["url"]
+			}
+			return conn.keys[field]
+		}
+
+		if conn.URL != "" {
+			printSetting("url", redactURL(conn.URL), conn.keys["url"])
+		}
+		printSetting("driver", conn.Driver, source("driver"))
+		printSetting("host", conn.Host, source("host"))
+		printSetting("port", conn.Port, source("port"))
+		printSetting("database", conn.Database, source("database"))
+		printSetting("username", conn.Username, source("username"))
+		printSetting("password", redact(conn.Password), source("password"))
+		for _, field := range []struct{ name, value string }{
+			{"socket", conn.Socket},
+			{"charset", conn.Charset},
+			{"instance", conn.Instance},
+			{"sslmode", conn.SSLMode},
+			{"sslrootcert", conn.SSLRootCert},
+			{"options", redactOptions(conn.Options)},
+			{"retries", conn.Retries},
+			{"retry_backoff", conn.RetryBackoff},
+			{"role", conn.Role},
+			{"init", redactInit(conn.Init, conn.Driver)},
+		} {
+			if field.value != "" {
+				printSetting(field.name, field.value, source(field.name))
+			}
+		}
+		printSetting("migrations_path", conn.MigrationsPath, conn.keys["migrations_path"])
+		printSetting("seeders_path", conn.SeedersPath, conn.keys["seeders_path"])
+	}
+	fmt.Println()
+}
+
+func printSetting(name, value, key string) {
+	source := "default"
+	if origin, ok := sources[key]; ok && os.Getenv(key) != "" {
+		source = fmt.Sprintf("%s from %s", key, origin)
+	}
+	fmt.Printf("  %-18s %-40s ", name, value)
+	color.White("%s\n", source)
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "********"
+}
+
+// secretOptions are driver parameters that hold credentials.
+var secretOptions = map[string]bool{
+	"password": true, "passwd": true, "pwd": true, "sslpassword": true,
+	"secret": true, "token": true, "access_token": true, "accesstoken": true,
+	"sslkey": true, "client_secret": true,
+}
+
+// redactOptions masks the values of DB_OPTIONS parameters that hold
+// credentials and keeps the rest readable.
+func redactOptions(options string) string {
+	if options == "" {
+		return ""
+	}
+	params := strings.Split(options, "&")
+	for i, param := range params {
+		key, _, found := strings.Cut(param, "=")
+		if found && secretOptions[strings.ToLower(key)] {
+			params[i] = key + "=********"
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// redactInit keeps the first word of each DB_INIT statement, e.g. "SET",
+// and masks the rest, which may hold a password.
+func redactInit(init, driver string) string {
+	var masked []string
+	for _, stmt := range statement.Split(init, driver, 1) {
+		masked = append(masked, strings.Fields(stmt.SQL)[0]+" ********")
+	}
+	return strings.Join(masked, "; ")
+}
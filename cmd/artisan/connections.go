@@ -0,0 +1,293 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hymns/go-artisan/migration"
+	"github.com/hymns/go-artisan/seeder"
+)
+
+// connection is one named database with its own migrations and seeders.
+// DB stays nil for commands that only generate files.
+type connection struct {
+	Name     string
+	Driver   string
+	Host     string
+	Port     string
+	Database string
+	Username string
+	Password string
+
+	// URL is DATABASE_URL/DB_URL. Its parts override the fields above.
+	URL         string
+	Options     string // extra driver parameters, e.g. timeout=5s&readTimeout=30s
+	SSLMode     string // disable, require, verify-ca, verify-full
+	SSLRootCert string
+	Socket      string
+	Charset     string
+	Instance    string // SQL Server named instance, from sqlserver://host/instance
+
+	MigrationsPath string
+	SeedersPath    string
+	Default        bool
+
+	// Shards are URLs or driver DSNs of identically migrated databases that
+	// make up this one logical connection.
+	Shards []string
+
+	// Retries is how often a migration or seeder is run again after a
+	// deadlock or dropped connection; RetryBackoff is the first delay.
+	Retries      string
+	RetryBackoff string
+
+	// Role and Init set up the session before every migration and seeder,
+	// e.g. DB_ROLE=migrator and DB_INIT=SET lock_timeout = '5s'
+	Role string
+	Init string
+
+	// Wait is how long open keeps retrying until the database is up (--wait)
+	Wait time.Duration
+
+	// Timeout limits each migration and seeder (--timeout)
+	Timeout time.Duration
+
+	DB *sql.DB
+
+	// keys records which environment variable each field was read from
+	keys map[string]string
+	err  error
+
+	// rawDSN is a shard given as a driver DSN rather than a URL
+	rawDSN string
+}
+
+// loadConnections reads DB_CONNECTIONS=main,analytics and each connection's
+// <NAME>_DB_* variables. The default connection also falls back to the plain
+// DB_* variables, so a single connection setup keeps working unchanged.
+func loadConnections() []*connection {
+	names := splitList(getEnv("DB_CONNECTIONS", ""))
+	if len(names) == 0 {
+		return []*connection{newConnection("default", "", true)}
+	}
+
+	def := defaultConnectionName(names)
+
+	var connections []*connection
+	for _, name := range names {
+		prefix := strings.ToUpper(name) + "_"
+		connections = append(connections, newConnection(name, prefix, name == def))
+	}
+	return connections
+}
+
+func defaultConnectionName(names []string) string {
+	if name := getEnv("DB_CONNECTION", ""); name != "" {
+		return name
+	}
+	return names[0]
+}
+
+func newConnection(name, prefix string, isDefault bool) *connection {
+	c := &connection{Name: name, Default: isDefault, keys: make(map[string]string)}
+
+	// lookup tries the prefixed variables, then the plain ones for the
+	// default connection, and remembers which variable was used
+	lookup := func(field, defaultValue string, keys ...string) string {
+		var candidates []string
+		if prefix != "" {
+			for _, key := range keys {
+				candidates = append(candidates, prefix+key)
+			}
+		}
+		if prefix == "" || isDefault {
+			candidates = append(candidates, keys...)
+		}
+		for _, key := range candidates {
+			if value := getEnv(key, ""); value != "" {
+				c.keys[field] = key
+				return value
+			}
+		}
+		return defaultValue
+	}
+
+	c.Driver = lookup("driver", "mysql", "DB_DRIVER")
+	c.Host = lookup("host", "localhost", "DB_HOST")
+	c.Port = lookup("port", defaultPort(c.Driver), "DB_PORT")
+	c.Database = lookup("database", "database", "DB_DATABASE", "DB_NAME")
+	c.Username = lookup("username", "root", "DB_USERNAME", "DB_USER")
+	c.Password = lookup("password", "", "DB_PASSWORD", "DB_PASS")
+	c.URL = lookup("url", "", "DATABASE_URL", "DB_URL")
+	c.Options = lookup("options", "", "DB_OPTIONS")
+	c.SSLMode = lookup("sslmode", "", "DB_SSLMODE")
+	c.SSLRootCert = lookup("sslrootcert", "", "DB_SSLROOTCERT")
+	c.Socket = lookup("socket", "", "DB_SOCKET")
+	c.Charset = lookup("charset", "", "DB_CHARSET")
+	if c.URL != "" {
+		c.err = c.applyURL()
+	}
+	c.Shards = splitShards(lookup("shards", "", "DB_SHARDS"))
+	c.Retries = lookup("retries", "", "DB_RETRIES")
+	c.RetryBackoff = lookup("retry_backoff", "", "DB_RETRY_BACKOFF")
+	c.Role = lookup("role", "", "DB_ROLE")
+	c.Init = lookup("init", "", "DB_INIT")
+	c.MigrationsPath = lookup("migrations_path", "./database/migrations", "MIGRATIONS_PATH")
+	c.SeedersPath = lookup("seeders_path", "./database/seeders", "SEEDERS_PATH")
+
+	// Other connections get their own subdirectory unless a path is set
+	if !isDefault {
+		if _, ok := c.keys["migrations_path"]; !ok {
+			c.MigrationsPath = filepath.Join(getEnv("MIGRATIONS_PATH", c.MigrationsPath), name)
+		}
+		if _, ok := c.keys["seeders_path"]; !ok {
+			c.SeedersPath = filepath.Join(getEnv("SEEDERS_PATH", c.SeedersPath), name)
+		}
+	}
+
+	return c
+}
+
+func defaultPort(driver string) string {
+	switch driver {
+	case "postgres":
+		return "5432"
+	case "sqlserver", "mssql":
+		return "1433"
+	default:
+		return "3306"
+	}
+}
+
+// findConnection returns the named connection, or the default one when name
+// is empty.
+func findConnection(name string) (*connection, error) {
+	connections := loadConnections()
+	for _, c := range connections {
+		if (name == "" && c.Default) || c.Name == name {
+			return c, nil
+		}
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("default connection %q is not listed in DB_CONNECTIONS", getEnv("DB_CONNECTION", ""))
+	}
+	return nil, fmt.Errorf("unknown connection %q (available: %s)", name, strings.Join(connectionNames(connections), ", "))
+}
+
+func connectionNames(connections []*connection) []string {
+	names := make([]string, len(connections))
+	for i, c := range connections {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func (c *connection) open() error {
+	if c.err != nil {
+		return c.err
+	}
+
+	dsn, err := c.dsn()
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open(sqlDriverName(c.Driver), dsn)
+	if err != nil {
+		return err
+	}
+
+	if c.Wait > 0 {
+		err = migration.WaitForDB(db, c.Wait)
+	} else {
+		err = db.Ping()
+	}
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	c.DB = db
+	return nil
+}
+
+// parseDurationFlag reads a flag such as --wait=60s or --timeout=5m.
+func parseDurationFlag(args []string, flag string) (time.Duration, error) {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, flag+"=") {
+			value := strings.TrimPrefix(arg, flag+"=")
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				return 0, fmt.Errorf("invalid %s value: %s", flag, value)
+			}
+			return duration, nil
+		}
+	}
+	return 0, nil
+}
+
+// needsOpen reports whether the connection's own database is used. Shards
+// and tenants with their own database or SQLite file are opened one by one.
+func (c *connection) needsOpen(args []string) bool {
+	if len(c.Shards) > 0 {
+		return false
+	}
+	return !(hasTenantFlags(args) && c.isTenantTemplate())
+}
+
+func (c *connection) close() {
+	if c.DB != nil {
+		c.DB.Close()
+	}
+}
+
+func (c *connection) migration() *migration.Migration {
+	m := migration.New(c.DB)
+	m.Driver = c.Driver
+	m.Retry, _ = c.retryPolicy()
+	m.InitStatements, _ = c.initStatements()
+	m.Timeout = c.Timeout
+	return m
+}
+
+func (c *connection) seeder() *seeder.Seeder {
+	// The driver is known from config, so skip seeder.New's detection query
+	s := &seeder.Seeder{DB: c.DB, Driver: c.Driver}
+	s.Retry, _ = c.retryPolicy()
+	s.InitStatements, _ = c.initStatements()
+	s.Timeout = c.Timeout
+	return s
+}
+
+// parseConnectionFlags removes --database=<name> and --all from args.
+func parseConnectionFlags(args []string) ([]string, string, bool) {
+	var rest []string
+	var name string
+	all := false
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--database=") {
+			name = strings.TrimPrefix(arg, "--database=")
+		} else if arg == "--all" {
+			all = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+
+	return rest, name, all
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
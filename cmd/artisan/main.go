@@ -1,24 +1,31 @@
 package main
 
 import (
-	"database/sql"
+	"bufio"
+	"context"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/hymns/go-artisan/dashboard"
+	"github.com/hymns/go-artisan/history"
 	"github.com/hymns/go-artisan/migration"
-	"github.com/hymns/go-artisan/seeder"
-	"github.com/joho/godotenv"
+	"github.com/hymns/go-artisan/stub"
+	"github.com/hymns/go-artisan/watch"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	_ "github.com/microsoft/go-mssqldb"
 )
 
 func main() {
-	loadEnvFile()
+	loadEnvironment()
 
 	if len(os.Args) < 2 {
 		printUsage()
@@ -26,34 +33,140 @@ func main() {
 	}
 
 	command := os.Args[1]
-	args := os.Args[2:]
+	args, database, all := parseConnectionFlags(os.Args[2:])
 
-	db, err := connectDB()
+	wait, err := parseDurationFlag(args, "--wait")
 	if err != nil {
-		color.Red("✗ Failed to connect to database: %v", err)
+		color.Red("✗ %v", err)
 		os.Exit(1)
 	}
-	defer db.Close()
+
+	// Commands that only generate or read files do not connect
+	switch command {
+	case "about", "help", "--help", "-h", "stub:publish":
+		runCommand(command, nil, args)
+		return
+	case "config:show":
+		handleConfigShow(database)
+		return
+	case "make:migration", "make:seeder", "migrate:lint":
+		conn, err := findConnection(database)
+		if err != nil {
+			color.Red("✗ %v", err)
+			os.Exit(1)
+		}
+		runCommand(command, conn, args)
+		return
+	}
+
+	if all {
+		if command != "migrate" && command != "db:migrate" && command != "migrate:status" {
+			color.Red("✗ --all is only supported by migrate and migrate:status")
+			os.Exit(1)
+		}
+		if command != "migrate:status" {
+			// Ask once for every connection
+			if !confirmToProceed(args) {
+				os.Exit(1)
+			}
+			args = append(args, "--force")
+		}
+
+		for _, conn := range loadConnections() {
+			color.Cyan("\n=== Connection: %s (%s) ===", conn.Name, conn.Driver)
+			conn.Wait = wait
+			if conn.needsOpen(args) {
+				if err := conn.open(); err != nil {
+					color.Red("✗ Failed to connect to database %s: %v", conn.Name, err)
+					os.Exit(1)
+				}
+			}
+			runCommand(command, conn, args)
+			conn.close()
+		}
+		return
+	}
+
+	conn, err := findConnection(database)
+	if err != nil {
+		color.Red("✗ %v", err)
+		os.Exit(1)
+	}
+	conn.Wait = wait
+
+	if conn.needsOpen(args) {
+		if err := conn.open(); err != nil {
+			color.Red("✗ Failed to connect to database: %v", err)
+			os.Exit(1)
+		}
+		defer conn.close()
+	}
+
+	runCommand(command, conn, args)
+}
+
+func runCommand(command string, conn *connection, args []string) {
+	if hasSelectionFlags(args) && !selectionCommands[command] {
+		color.Red("✗ --tag and --phase are only supported by migrate, migrate:dry-run and migrate:status")
+		os.Exit(1)
+	}
+	if conn != nil {
+		if err := conn.applyRetryFlags(args); err != nil {
+			color.Red("✗ %v", err)
+			os.Exit(1)
+		}
+		if _, err := conn.initStatements(); err != nil {
+			color.Red("✗ %v", err)
+			os.Exit(1)
+		}
+		timeout, err := parseDurationFlag(args, "--timeout")
+		if err != nil {
+			color.Red("✗ %v", err)
+			os.Exit(1)
+		}
+		conn.Timeout = timeout
+	}
+
+	if conn != nil && hasTenantFlags(args) {
+		handleTenants(conn, command, args)
+		return
+	}
+	if conn != nil && len(conn.Shards) > 0 && !strings.HasPrefix(command, "make:") {
+		handleShards(conn, command, args)
+		return
+	}
 
 	switch command {
 	case "migrate", "db:migrate":
-		handleMigrate(db, args)
+		handleMigrate(conn, args)
 	case "migrate:rollback", "db:rollback":
-		handleMigrateRollback(db, args)
+		handleMigrateRollback(conn, args)
 	case "migrate:fresh":
-		handleMigrateFresh(db, args)
+		handleMigrateFresh(conn, args)
 	case "migrate:status":
-		handleMigrateStatus(db)
+		handleMigrateStatus(conn, args)
 	case "migrate:dry-run", "migrate:dryrun":
-		handleMigrateDryRun(db)
+		handleMigrateDryRun(conn, args)
+	case "migrate:lint":
+		handleMigrateLint(conn)
+	case "migrate:watch":
+		handleMigrateWatch(conn, args)
+	case "migrate:history":
+		handleMigrateHistory(conn, args)
 	case "db:seed":
-		handleSeed(db, args)
+		handleSeed(conn, args)
+	case "db:wipe":
+		handleDBWipe(conn, args)
 	case "seeder:status", "db:seed:status":
-		handleSeederStatus(db)
+		handleSeederStatus(conn)
 	case "make:migration":
-		handleMakeMigration(args)
+		handleMakeMigration(conn, args)
 	case "make:seeder":
-		handleMakeSeeder(args)
+		handleMakeSeeder(conn, args)
+	case "stub:publish":
+		handleStubPublish(args)
+	case "serve":
+		handleServe(conn, args)
 	case "about":
 		printAbout()
 	case "help", "--help", "-h":
@@ -65,61 +178,14 @@ func main() {
 	}
 }
 
-func loadEnvFile() {
-	cwd, err := os.Getwd()
-	if err != nil {
-		color.Yellow("Warning: Could not get current directory")
-		return
-	}
-
-	envPath := filepath.Join(cwd, ".env")
-	if _, err := os.Stat(envPath); err == nil {
-		if err := godotenv.Load(envPath); err != nil {
-			color.Yellow("Warning: Failed to load .env file")
-		}
-	} else {
-		color.Yellow("Warning: .env file not found in current directory")
-	}
-}
-
-func connectDB() (*sql.DB, error) {
-	dbDriver := getEnv("DB_DRIVER", "mysql")
-	dbHost := getEnv("DB_HOST", "localhost")
-	dbPort := getEnv("DB_PORT", "3306")
-	dbName := getEnvWithFallback("DB_DATABASE", "DB_NAME", "database")
-	dbUser := getEnvWithFallback("DB_USERNAME", "DB_USER", "root")
-	dbPass := getEnvWithFallback("DB_PASSWORD", "DB_PASS", "")
-
-	var dsn string
-	switch dbDriver {
-	case "mysql":
-		dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", dbUser, dbPass, dbHost, dbPort, dbName)
-	case "postgres":
-		dsn = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", dbHost, dbPort, dbUser, dbPass, dbName)
-	case "sqlserver", "mssql":
-		dsn = fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s", dbUser, dbPass, dbHost, dbPort, dbName)
-	case "sqlite", "sqlite3":
-		// For SQLite, dbName is the file path
-		dsn = dbName
-	default:
-		return nil, fmt.Errorf("unsupported database driver: %s", dbDriver)
-	}
-
-	db, err := sql.Open(dbDriver, dsn)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := db.Ping(); err != nil {
-		return nil, err
+func handleMigrate(conn *connection, args []string) {
+	if !confirmToProceed(args) {
+		os.Exit(1)
 	}
 
-	return db, nil
-}
-
-func handleMigrate(db *sql.DB, args []string) {
-	m := migration.New(db)
-	migrationsPath := getEnv("MIGRATIONS_PATH", "./database/migrations")
+	m := conn.migration()
+	selectMigrations(m, args)
+	migrationsPath := conn.MigrationsPath
 
 	// Parse flags
 	var specificPath string
@@ -149,16 +215,24 @@ func handleMigrate(db *sql.DB, args []string) {
 	if runSeed {
 		fmt.Println()
 		color.Cyan("Running seeders...")
-		handleSeed(db, []string{})
+		handleSeed(conn, []string{"--force"})
 	}
 }
 
-func handleMigrateRollback(db *sql.DB, args []string) {
-	m := migration.New(db)
-	migrationsPath := getEnv("MIGRATIONS_PATH", "./database/migrations")
+func handleMigrateRollback(conn *connection, args []string) {
+	if !confirmToProceed(args) {
+		os.Exit(1)
+	}
+
+	m := conn.migration()
+	migrationsPath := conn.MigrationsPath
 
-	// Parse --step flag, default to 1
+	// Parse --step, --batch, --path, --allow-irreversible and --ignore-later
+	// flags, default to 1 step
 	steps := 1
+	batch := 0
+	var specificPath string
+	ignoreLater := false
 	for _, arg := range args {
 		if strings.HasPrefix(arg, "--step=") {
 			stepStr := strings.TrimPrefix(arg, "--step=")
@@ -166,52 +240,87 @@ func handleMigrateRollback(db *sql.DB, args []string) {
 				color.Red("✗ Invalid --step value: %s", stepStr)
 				os.Exit(1)
 			}
+		} else if strings.HasPrefix(arg, "--batch=") {
+			batchStr := strings.TrimPrefix(arg, "--batch=")
+			if s, err := fmt.Sscanf(batchStr, "%d", &batch); err != nil || s != 1 || batch < 1 {
+				color.Red("✗ Invalid --batch value: %s", batchStr)
+				os.Exit(1)
+			}
+		} else if strings.HasPrefix(arg, "--path=") {
+			specificPath = strings.TrimPrefix(arg, "--path=")
+		} else if arg == "--ignore-later" {
+			ignoreLater = true
 		}
 	}
 
-	// Rollback N steps
-	for i := 0; i < steps; i++ {
-		if err := m.Rollback(migrationsPath); err != nil {
+	if batch > 0 && specificPath != "" {
+		color.Red("✗ Use either --batch or --path, not both")
+		os.Exit(1)
+	}
+
+	m.AllowIrreversible = allowIrreversible(args)
+
+	// Rollback a single migration file if --path provided
+	if specificPath != "" {
+		if err := m.RollbackFile(specificPath, ignoreLater); err != nil {
+			color.Red("✗ Rollback failed: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Rollback a specific batch if --batch provided
+	if batch > 0 {
+		if err := m.RollbackBatch(migrationsPath, batch, ignoreLater); err != nil {
 			color.Red("✗ Rollback failed: %v", err)
 			os.Exit(1)
 		}
+		return
+	}
+
+	// Rollback N steps
+	if err := m.RollbackSteps(migrationsPath, steps); err != nil {
+		color.Red("✗ Rollback failed: %v", err)
+		os.Exit(1)
 	}
 }
 
-func handleMigrateFresh(db *sql.DB, args []string) {
-	m := migration.New(db)
-	migrationsPath := getEnv("MIGRATIONS_PATH", "./database/migrations")
+func handleMigrateFresh(conn *connection, args []string) {
+	if !confirmToProceed(args) {
+		os.Exit(1)
+	}
+
+	m := conn.migration()
+	migrationsPath := conn.MigrationsPath
 
-	// Parse --seed flag
+	// Parse --seed, --drop-views and --drop-types flags
 	runSeed := false
+	opts := parseWipeOptions(args)
 	for _, arg := range args {
 		if arg == "--seed" {
 			runSeed = true
-			break
 		}
 	}
 
-	color.Cyan("Rolling back all migrations...")
-
-	// Rollback all migrations
-	for {
-		batch, err := m.GetLastBatch()
-		if err != nil {
-			color.Red("✗ Failed to get last batch: %v", err)
-			os.Exit(1)
-		}
+	// Rebuilding would lose what irreversible migrations did
+	irreversible, err := m.IrreversibleApplied(migrationsPath)
+	if err != nil {
+		color.Red("✗ Failed to check migrations: %v", err)
+		os.Exit(1)
+	}
+	if len(irreversible) > 0 && !allowIrreversible(args) {
+		color.Red("✗ Refusing to rebuild the database: %s cannot be rolled back (use --allow-irreversible to override)", strings.Join(irreversible, ", "))
+		os.Exit(1)
+	}
 
-		if batch == 0 {
-			break
-		}
+	color.Cyan("Dropping all tables...")
 
-		if err := m.Rollback(migrationsPath); err != nil {
-			color.Red("✗ Rollback failed: %v", err)
-			os.Exit(1)
-		}
+	if err := m.Wipe(opts); err != nil {
+		color.Red("✗ Wipe failed: %v", err)
+		os.Exit(1)
 	}
 
-	color.Green("✓ All migrations rolled back")
+	color.Green("✓ Dropped all tables successfully")
 
 	// Re-run all migrations
 	fmt.Println()
@@ -225,13 +334,42 @@ func handleMigrateFresh(db *sql.DB, args []string) {
 	if runSeed {
 		fmt.Println()
 		color.Cyan("Running seeders...")
-		handleSeed(db, []string{})
+		handleSeed(conn, []string{"--force"})
 	}
 }
 
-func handleMigrateStatus(db *sql.DB) {
-	m := migration.New(db)
-	migrationsPath := getEnv("MIGRATIONS_PATH", "./database/migrations")
+func handleDBWipe(conn *connection, args []string) {
+	if !confirmToProceed(args) {
+		os.Exit(1)
+	}
+
+	m := conn.migration()
+
+	if err := m.Wipe(parseWipeOptions(args)); err != nil {
+		color.Red("✗ Wipe failed: %v", err)
+		os.Exit(1)
+	}
+
+	color.Green("✓ Dropped all tables successfully")
+}
+
+func parseWipeOptions(args []string) migration.WipeOptions {
+	var opts migration.WipeOptions
+	for _, arg := range args {
+		switch arg {
+		case "--drop-views":
+			opts.DropViews = true
+		case "--drop-types":
+			opts.DropTypes = true
+		}
+	}
+	return opts
+}
+
+func handleMigrateStatus(conn *connection, args []string) {
+	m := conn.migration()
+	selectMigrations(m, args)
+	migrationsPath := conn.MigrationsPath
 
 	statuses, err := m.Status(migrationsPath)
 	if err != nil {
@@ -245,23 +383,136 @@ func handleMigrateStatus(db *sql.DB) {
 	}
 
 	color.Cyan("\nMigration Status:\n")
-	color.White("%-50s %-10s %s\n", "Migration", "Batch", "Ran")
-	color.White("%s\n", strings.Repeat("-", 70))
+	color.White("%-50s %-10s %-5s %-9s %s\n", "Migration", "Batch", "Ran", "Phase", "Tags")
+	color.White("%s\n", strings.Repeat("-", 90))
+
+	var waiting []string
 
 	for _, status := range statuses {
 		if status.Migrated {
 			fmt.Printf("%-50s %-10d ", status.Name, status.Batch)
-			color.Green("YES\n")
+			color.New(color.FgGreen).Printf("%-5s ", "YES")
 		} else {
 			fmt.Printf("%-50s %-10s ", status.Name, "-")
-			color.Yellow("NO\n")
+			color.New(color.FgYellow).Printf("%-5s ", "NO")
+		}
+		fmt.Printf("%-9s %s\n", status.Phase, strings.Join(status.Tags, ", "))
+		for _, dep := range status.Depends {
+			color.White("  ↳ depends on %s\n", dep)
 		}
+		if !status.Migrated && status.Phase == migration.PhaseContract {
+			waiting = append(waiting, status.Name)
+		}
+		for _, warning := range status.Warnings {
+			color.Yellow("  ⚠ %s\n", warning)
+		}
+	}
+
+	if len(waiting) > 0 {
+		color.Yellow("\n⚠ %d contract migration(s) waiting; run migrate --phase=contract once the new code is deployed:", len(waiting))
+		for _, name := range waiting {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+}
+
+func handleMigrateLint(conn *connection) {
+	m := conn.migration()
+	migrationsPath := conn.MigrationsPath
+
+	issues, err := m.Lint(migrationsPath)
+	if err != nil {
+		color.Red("✗ Lint failed: %v", err)
+		os.Exit(1)
+	}
+
+	if len(issues) == 0 {
+		color.Green("✓ No problems found for driver %s", m.Driver)
+		return
+	}
+
+	for _, issue := range issues {
+		color.Yellow("⚠ %s: %s", issue.File, issue.Message)
 	}
+	color.Red("✗ %d problem(s) found", len(issues))
+	os.Exit(1)
 }
 
-func handleMigrateDryRun(db *sql.DB) {
-	m := migration.New(db)
-	migrationsPath := getEnv("MIGRATIONS_PATH", "./database/migrations")
+func handleMigrateHistory(conn *connection, args []string) {
+	m := conn.migration()
+
+	// Parse --migration, --since, --until and --limit flags
+	var filter history.Filter
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--migration=") {
+			filter.Migration = strings.TrimPrefix(arg, "--migration=")
+		} else if strings.HasPrefix(arg, "--since=") {
+			filter.Since = parseDateFlag("--since", strings.TrimPrefix(arg, "--since="))
+		} else if strings.HasPrefix(arg, "--until=") {
+			value := strings.TrimPrefix(arg, "--until=")
+			filter.Until = parseDateFlag("--until", value)
+			// A date on its own includes that whole day
+			if len(value) == len("2006-01-02") {
+				filter.Until = filter.Until.AddDate(0, 0, 1)
+			}
+		} else if strings.HasPrefix(arg, "--limit=") {
+			limitStr := strings.TrimPrefix(arg, "--limit=")
+			if s, err := fmt.Sscanf(limitStr, "%d", &filter.Limit); err != nil || s != 1 {
+				color.Red("✗ Invalid --limit value: %s", limitStr)
+				os.Exit(1)
+			}
+		}
+	}
+
+	entries, err := m.History(filter)
+	if err != nil {
+		color.Red("✗ Failed to get migration history: %v", err)
+		os.Exit(1)
+	}
+
+	if len(entries) == 0 {
+		color.Cyan("No migration history found.")
+		return
+	}
+
+	color.Cyan("\nMigration History:\n")
+	color.White("%-20s %-45s %-5s %-6s %-9s %-10s %s\n", "Date", "Migration", "Dir", "Batch", "Outcome", "Duration", "By")
+	color.White("%s\n", strings.Repeat("-", 120))
+
+	for _, e := range entries {
+		fmt.Printf("%-20s %-45s %-5s %-6d ", e.CreatedAt.Local().Format("2006-01-02 15:04:05"), e.Migration, e.Direction, e.Batch)
+		switch e.Outcome {
+		case history.OutcomeSuccess:
+			color.New(color.FgGreen).Printf("%-9s ", e.Outcome)
+		case history.OutcomeFailure:
+			color.New(color.FgRed).Printf("%-9s ", e.Outcome)
+		default:
+			color.New(color.FgYellow).Printf("%-9s ", e.Outcome)
+		}
+		fmt.Printf("%-10s %s@%s\n", e.Duration, e.User, e.Host)
+		if e.Error != "" {
+			color.Red("  %s", e.Error)
+		}
+	}
+}
+
+// parseDateFlag accepts a date (2006-01-02) or an RFC 3339 timestamp.
+func parseDateFlag(flag, value string) time.Time {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	color.Red("✗ Invalid %s value: %s (expected YYYY-MM-DD)", flag, value)
+	os.Exit(1)
+	return time.Time{}
+}
+
+func handleMigrateDryRun(conn *connection, args []string) {
+	m := conn.migration()
+	selectMigrations(m, args)
+	migrationsPath := conn.MigrationsPath
 
 	if err := m.DryRun(migrationsPath); err != nil {
 		color.Red("✗ Dry run failed: %v", err)
@@ -269,9 +520,46 @@ func handleMigrateDryRun(db *sql.DB) {
 	}
 }
 
-func handleSeed(db *sql.DB, args []string) {
-	s := seeder.New(db)
-	seedersPath := getEnv("SEEDERS_PATH", "./database/seeders")
+func handleMigrateWatch(conn *connection, args []string) {
+	migrationsPath := conn.MigrationsPath
+	seedersPath := conn.SeedersPath
+
+	w := watch.New(conn.migration(), conn.seeder(), migrationsPath, seedersPath)
+	w.Production = getEnv("APP_ENV", "") == "production"
+
+	// Parse --interval flag, default to 1s
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--interval=") {
+			intervalStr := strings.TrimPrefix(arg, "--interval=")
+			interval, err := time.ParseDuration(intervalStr)
+			if err != nil || interval <= 0 {
+				color.Red("✗ Invalid --interval value: %s", intervalStr)
+				os.Exit(1)
+			}
+			w.Interval = interval
+		}
+	}
+
+	if w.Production {
+		color.Yellow("⚠ APP_ENV=production: edited migrations will not be rolled back automatically")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := w.Run(ctx); err != nil {
+		color.Red("✗ Watch failed: %v", err)
+		os.Exit(1)
+	}
+}
+
+func handleSeed(conn *connection, args []string) {
+	if !confirmToProceed(args) {
+		os.Exit(1)
+	}
+
+	s := conn.seeder()
+	seedersPath := conn.SeedersPath
 
 	// Parse --path flag
 	var specificPath string
@@ -297,9 +585,15 @@ func handleSeed(db *sql.DB, args []string) {
 	}
 }
 
-func handleSeederStatus(db *sql.DB) {
-	s := seeder.New(db)
-	seedersPath := getEnv("SEEDERS_PATH", "./database/seeders")
+func handleSeederStatus(conn *connection) {
+	s := conn.seeder()
+	seedersPath := conn.SeedersPath
+
+	// Status does not create the table, and without it lists nothing
+	if err := s.EnsureSeedersTable(); err != nil {
+		color.Red("✗ Failed to ensure seeders table: %v", err)
+		os.Exit(1)
+	}
 
 	statuses, err := s.Status(seedersPath)
 	if err != nil {
@@ -326,13 +620,15 @@ func handleSeederStatus(db *sql.DB) {
 	}
 }
 
-func handleMakeMigration(args []string) {
-	var tableName, migrationName string
+func handleMakeMigration(conn *connection, args []string) {
+	var tableName, migrationName, columnsSpec string
 
 	// Parse arguments and flags
 	for i, arg := range args {
 		if strings.HasPrefix(arg, "--table=") {
 			tableName = strings.TrimPrefix(arg, "--table=")
+		} else if strings.HasPrefix(arg, "--columns=") {
+			columnsSpec = strings.TrimPrefix(arg, "--columns=")
 		} else if i == 0 && !strings.HasPrefix(arg, "--") {
 			migrationName = arg
 		}
@@ -341,6 +637,13 @@ func handleMakeMigration(args []string) {
 	if migrationName == "" {
 		color.Red("✗ Usage: artisan make:migration <migration_name>")
 		color.Red("✗    or: artisan make:migration <migration_name> --table=<table_name>")
+		color.Red("✗    or: artisan make:migration <migration_name> --columns=<name:type[:modifier]>,...")
+		os.Exit(1)
+	}
+
+	columns, err := migration.ParseColumns(columnsSpec)
+	if err != nil {
+		color.Red("✗ Invalid --columns value: %v", err)
 		os.Exit(1)
 	}
 
@@ -365,32 +668,19 @@ func handleMakeMigration(args []string) {
 		migrationName = fmt.Sprintf("create_%s_table", tableName)
 	}
 
-	// If no table name set yet, try to extract from migration name
-	if tableName == "" {
-		// Extract table name from migration name patterns like "create_users_table"
-		for _, prefix := range prefixWords {
-			if strings.HasPrefix(migrationName, prefix) {
-				// Remove prefix and "_table" suffix if exists
-				temp := strings.TrimPrefix(migrationName, prefix)
-				temp = strings.TrimSuffix(temp, "_table")
-				if temp != "" {
-					tableName = temp
-				}
-				break
-			}
-		}
-	}
+	// The table is otherwise taken from the migration name, e.g. add_email_to_users
 
-	migrationsPath := getEnv("MIGRATIONS_PATH", "./database/migrations")
+	migrationsPath := conn.MigrationsPath
 
-	m := migration.New(nil)
-	if err := m.MakeMigration(tableName, migrationName, migrationsPath); err != nil {
+	m := conn.migration()
+	m.StubsPath = getEnv("STUBS_PATH", stub.DefaultPath)
+	if err := m.MakeMigrationWithColumns(tableName, migrationName, migrationsPath, columns); err != nil {
 		color.Red("✗ Failed to create migration: %v", err)
 		os.Exit(1)
 	}
 }
 
-func handleMakeSeeder(args []string) {
+func handleMakeSeeder(conn *connection, args []string) {
 	var seederName string
 
 	// Parse arguments and flags
@@ -413,15 +703,68 @@ func handleMakeSeeder(args []string) {
 		seederName = seederName + "_seeder"
 	}
 
-	seedersPath := getEnv("SEEDERS_PATH", "./database/seeders")
+	seedersPath := conn.SeedersPath
 
-	s := seeder.New(nil)
+	s := conn.seeder()
+	s.StubsPath = getEnv("STUBS_PATH", stub.DefaultPath)
 	if err := s.MakeSeeder(seederName, seedersPath); err != nil {
 		color.Red("✗ Failed to create seeder: %v", err)
 		os.Exit(1)
 	}
 }
 
+func handleStubPublish(args []string) {
+	force := false
+	for _, arg := range args {
+		if arg == "--force" {
+			force = true
+		}
+	}
+
+	stubsPath := getEnv("STUBS_PATH", stub.DefaultPath)
+
+	written, err := stub.Publish(stubsPath, force)
+	if err != nil {
+		color.Red("✗ Failed to publish stubs: %v", err)
+		os.Exit(1)
+	}
+
+	if len(written) == 0 {
+		color.Yellow("⚠ Stubs already published to %s (use --force to overwrite)", stubsPath)
+		return
+	}
+
+	for _, path := range written {
+		color.Green("✓ Stub published: %s", path)
+	}
+}
+
+func handleServe(conn *connection, args []string) {
+	migrationsPath := conn.MigrationsPath
+	seedersPath := conn.SeedersPath
+
+	// Parse --host and --port flags, bind to localhost by default
+	host := "127.0.0.1"
+	port := "8000"
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--host=") {
+			host = strings.TrimPrefix(arg, "--host=")
+		} else if strings.HasPrefix(arg, "--port=") {
+			port = strings.TrimPrefix(arg, "--port=")
+		}
+	}
+
+	d := dashboard.New(conn.migration(), conn.seeder(), migrationsPath, seedersPath)
+	addr := net.JoinHostPort(host, port)
+
+	color.Green("✓ Dashboard running at http://%s", addr)
+	color.White("Press Ctrl+C to stop\n")
+	if err := http.ListenAndServe(addr, d); err != nil {
+		color.Red("✗ Server failed: %v", err)
+		os.Exit(1)
+	}
+}
+
 func printAbout() {
 	color.Cyan("\n╔════════════════════════════════════════════════════════════════╗\n")
 	color.Cyan("║                                                                ║\n")
@@ -465,21 +808,53 @@ func printUsage() {
 		{"migrate --seed", "Run migrations and seeders"},
 		{"migrate:rollback", "Rollback migrations (default: 1 step)"},
 		{"migrate:rollback --step=N", "Rollback N steps"},
-		{"migrate:fresh", "Rollback all, then re-run migrations"},
-		{"migrate:fresh --seed", "Rollback all, migrate, then seed"},
+		{"migrate:rollback --batch=N", "Rollback a specific batch"},
+		{"migrate:rollback --path=<file>", "Rollback a single migration"},
+		{"migrate:fresh", "Drop all tables, then re-run migrations"},
+		{"migrate:fresh --seed", "Drop all tables, migrate, then seed"},
+		{"migrate:fresh --drop-views --drop-types", "Also drop views and types"},
+		{"<command> --force", "Skip production confirmation (APP_ENV=production)"},
+		{"migrate:rollback --allow-irreversible", "Remove records of irreversible migrations (also migrate:fresh)"},
+		{"migrate:rollback --ignore-later", "Roll back --batch/--path while later migrations are applied"},
+		{"<command> --database=<name>", "Use a named connection from DB_CONNECTIONS"},
+		{"<command> --wait=<duration>", "Keep retrying until the database is up, e.g. --wait=60s"},
+		{"migrate --timeout=<duration>", "Roll back a migration that runs longer (also rollback/db:seed)"},
+		{"migrate --all, migrate:status --all", "Run on every connection"},
+		{"migrate --tenants=<file>", "Run for every tenant listed in a file (also rollback/status)"},
+		{"migrate --tenants-query=<sql>", "Run for every tenant returned by a query"},
+		{"migrate --phase=expand|contract", "Run only expand or contract migrations (also dry-run/status)"},
+		{"migrate --tag=<tag>", "Run only migrations with -- @tags: <tag> (also dry-run/status)"},
+		{"migrate --wave-size=N", "Sharded connections: canary shard, then N shards per wave"},
+		{"  --concurrency=N --continue-on-error", "Tenants run at once; keep going after a failure"},
+		{"migrate --retries=N --retry-backoff=<d>", "Retry after deadlocks and dropped connections (also db:seed)"},
 		{"migrate:status", "Show migration status (pending/migrated)"},
 		{"migrate:dry-run", "Preview pending migrations without running"},
+		{"migrate:lint", "Check migration files for the current driver"},
+		{"migrate:watch", "Watch migrations/seeders and apply changes"},
+		{"migrate:history", "Show audit log of migration/seeder runs"},
+		{"migrate:history --migration=<name>", "Filter history by migration name"},
+		{"migrate:history --since=<date> --until=<date>", "Filter history by date (YYYY-MM-DD)"},
 		{"db:seed", "Run database seeders"},
 		{"db:seed --path=<file>", "Run specific seeder file"},
 		{"seeder:status", "Show seeder status (seeded/pending)"},
+		{"db:wipe", "Drop all tables"},
+		{"db:wipe --drop-views --drop-types", "Drop all tables, views and types"},
 		{"", ""},
 		{"make:migration <name>", "Create migration with custom name"},
 		{"make:migration <table_name>", "Auto-create: create_<table_name>_table"},
 		{"make:migration <name> --table=<table>", "Create migration with table name"},
+		{"make:migration add_<col>_to_<table>", "ALTER TABLE skeleton (also drop_/rename_/alter_)"},
+		{"make:migration <name> --columns=<spec>", "Columns, e.g. email:string:unique,age:int:nullable"},
 		{"", ""},
 		{"make:seeder <name>", "Create seeder (auto-append: _seeder)"},
 		{"make:seeder --seeder=<name>", "Create seeder using flag"},
+		{"stub:publish", "Copy default stubs to database/stubs for customizing"},
+		{"stub:publish --force", "Overwrite previously published stubs"},
 		{"", ""},
+		{"serve", "Start local web dashboard (127.0.0.1:8000)"},
+		{"serve --host=<host> --port=<port>", "Start dashboard on a custom address"},
+		{"", ""},
+		{"config:show", "Show resolved configuration (secrets redacted)"},
 		{"about", "Show information about Artisan"},
 		{"help", "Show this help message"},
 	}
@@ -516,3 +891,76 @@ func getEnvWithFallback(primaryKey, fallbackKey, defaultValue string) string {
 
 	return defaultValue
 }
+
+// selectionCommands are the commands that accept --tag and --phase.
+var selectionCommands = map[string]bool{
+	"migrate":         true,
+	"db:migrate":      true,
+	"migrate:status":  true,
+	"migrate:dry-run": true,
+	"migrate:dryrun":  true,
+}
+
+func hasSelectionFlags(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--tag=") || strings.HasPrefix(arg, "--phase=") {
+			return true
+		}
+	}
+	return false
+}
+
+// selectMigrations applies --tag=billing,auth (repeatable) and
+// --phase=expand|contract to m.
+func selectMigrations(m *migration.Migration, args []string) {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--tag=") {
+			m.Tags = append(m.Tags, splitList(strings.TrimPrefix(arg, "--tag="))...)
+		} else if strings.HasPrefix(arg, "--phase=") {
+			m.Phase = strings.ToLower(strings.TrimPrefix(arg, "--phase="))
+			if m.Phase != migration.PhaseExpand && m.Phase != migration.PhaseContract {
+				color.Red("✗ Invalid --phase value: %s (use expand or contract)", m.Phase)
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// allowIrreversible reports whether --allow-irreversible was given. It is
+// separate from --force, which only skips the production prompt.
+func allowIrreversible(args []string) bool {
+	for _, arg := range args {
+		if arg == "--allow-irreversible" {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmToProceed asks for confirmation before running a command that can
+// change data when APP_ENV is production. --force skips the prompt.
+func confirmToProceed(args []string) bool {
+	if getEnv("APP_ENV", "") != "production" {
+		return true
+	}
+
+	for _, arg := range args {
+		if arg == "--force" {
+			return true
+		}
+	}
+
+	color.Yellow("**************************************")
+	color.Yellow("*     Application In Production!     *")
+	color.Yellow("**************************************")
+	fmt.Print("\nDo you really wish to run this command? (yes/no) [no]: ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "yes" || answer == "y" {
+		return true
+	}
+
+	color.Yellow("Command cancelled.")
+	return false
+}
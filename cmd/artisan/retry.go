@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hymns/go-artisan/retry"
+)
+
+// applyRetryFlags lets --retries and --retry-backoff override DB_RETRIES
+// and DB_RETRY_BACKOFF.
+func (c *connection) applyRetryFlags(args []string) error {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--retries=") {
+			c.Retries = strings.TrimPrefix(arg, "--retries=")
+		} else if strings.HasPrefix(arg, "--retry-backoff=") {
+			c.RetryBackoff = strings.TrimPrefix(arg, "--retry-backoff=")
+		}
+	}
+
+	_, err := c.retryPolicy()
+	return err
+}
+
+// retryPolicy turns the number of retries into a policy. No retries means
+// every migration and seeder is tried once.
+func (c *connection) retryPolicy() (retry.Policy, error) {
+	var policy retry.Policy
+
+	if c.Retries != "" {
+		retries, err := strconv.Atoi(c.Retries)
+		if err != nil || retries < 0 {
+			return policy, fmt.Errorf("invalid retries value: %s", c.Retries)
+		}
+		policy.MaxAttempts = retries + 1
+	}
+
+	if c.RetryBackoff != "" {
+		backoff, err := time.ParseDuration(c.RetryBackoff)
+		if err != nil || backoff <= 0 {
+			return policy, fmt.Errorf("invalid retry backoff: %s", c.RetryBackoff)
+		}
+		policy.Backoff = backoff
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hymns/go-artisan/statement"
+	"github.com/lib/pq"
+)
+
+// initStatements returns what runs before every migration and seeder: the
+// DB_ROLE switch, then the DB_INIT statements.
+func (c *connection) initStatements() ([]string, error) {
+	var statements []string
+
+	if c.Role != "" {
+		switch c.Driver {
+		case "postgres":
+			statements = append(statements, "SET ROLE "+pq.QuoteIdentifier(c.Role))
+		case "sqlserver", "mssql":
+			statements = append(statements, "EXECUTE AS USER = "+quoteString(c.Role))
+		case "sqlite", "sqlite3":
+			return nil, fmt.Errorf("DB_ROLE is not supported by SQLite")
+		default:
+			statements = append(statements, "SET ROLE "+quoteString(c.Role))
+		}
+	}
+
+	for _, stmt := range statement.Split(c.Init, c.Driver, 1) {
+		statements = append(statements, stmt.SQL)
+	}
+
+	return statements, nil
+}
+
+func quoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
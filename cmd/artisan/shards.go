@@ -0,0 +1,210 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/go-sql-driver/mysql"
+	"github.com/hymns/go-artisan/migration"
+)
+
+func handleShards(conn *connection, command string, args []string) {
+	opts := migration.RolloutOptions{WaveSize: 5}
+
+	// Parse --wave-size flag
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--wave-size=") {
+			waveStr := strings.TrimPrefix(arg, "--wave-size=")
+			if s, err := fmt.Sscanf(waveStr, "%d", &opts.WaveSize); err != nil || s != 1 || opts.WaveSize < 1 {
+				color.Red("✗ Invalid --wave-size value: %s", waveStr)
+				os.Exit(1)
+			}
+		} else if strings.HasPrefix(arg, "--step=") || strings.HasPrefix(arg, "--batch=") || strings.HasPrefix(arg, "--path=") || arg == "--seed" {
+			// Rollouts always apply all pending migrations or revert the last batch
+			color.Red("✗ %s is not supported on a sharded connection", strings.SplitN(arg, "=", 2)[0])
+			os.Exit(1)
+		}
+	}
+
+	shards, err := conn.shardConnections()
+	if err != nil {
+		color.Red("✗ %v", err)
+		os.Exit(1)
+	}
+
+	names := make([]string, len(shards))
+	byName := make(map[string]*connection)
+	for i, shard := range shards {
+		names[i] = shard.Name
+		byName[shard.Name] = shard
+	}
+
+	if command != "migrate:status" && !confirmToProceed(args) {
+		os.Exit(1)
+	}
+
+	opts.Connect = func(name string) (*sql.DB, error) {
+		shard := *byName[name]
+		if err := shard.open(); err != nil {
+			return nil, err
+		}
+		return shard.DB, nil
+	}
+	opts.OnWave = func(wave int, shards []string) {
+		if command == "migrate:status" {
+			return
+		}
+		if wave == 0 {
+			color.Cyan("\nCanary: %s", shards[0])
+		} else {
+			color.Cyan("\nWave %d: %s", wave, strings.Join(shards, ", "))
+		}
+	}
+
+	m := conn.migration()
+	selectMigrations(m, args)
+	m.AllowIrreversible = allowIrreversible(args)
+
+	color.Cyan("Running %s on %d shard(s) of %s (wave size %d)...", command, len(shards), conn.Name, opts.WaveSize)
+
+	var results []migration.ShardResult
+	switch command {
+	case "migrate", "db:migrate":
+		results = m.RolloutMigrate(conn.MigrationsPath, names, opts)
+	case "migrate:rollback", "db:rollback":
+		results = m.RolloutRollback(conn.MigrationsPath, names, opts)
+	case "migrate:status":
+		results = m.StatusShards(conn.MigrationsPath, names, opts)
+	default:
+		color.Red("✗ Connection %s is sharded; only migrate, migrate:rollback and migrate:status are supported", conn.Name)
+		os.Exit(1)
+	}
+
+	drift := migration.Drift(results)
+	drifted := make(map[string]bool)
+	for _, d := range drift {
+		drifted[d.Shard] = true
+	}
+
+	rows := make([]resultRow, len(results))
+	for i, result := range results {
+		rows[i] = resultRow{
+			Name:     result.Shard,
+			Err:      result.Err,
+			Duration: result.Duration,
+			Statuses: result.Statuses,
+			Drift:    drifted[result.Shard],
+		}
+	}
+	failed := printResults("Shard", rows)
+
+	if command == "migrate:status" {
+		printShardDrift(drift)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func printShardDrift(drift []migration.ShardDrift) {
+	if len(drift) == 0 {
+		color.Green("✓ All shards have the same migrations applied")
+		return
+	}
+
+	color.Yellow("\n⚠ %d shard(s) differ from the others:", len(drift))
+	for _, d := range drift {
+		color.Yellow("  %s", d.Shard)
+		for _, name := range d.Missing {
+			fmt.Printf("    - missing %s\n", name)
+		}
+		for _, name := range d.Extra {
+			fmt.Printf("    + extra   %s\n", name)
+		}
+	}
+}
+
+// shardConnections returns one connection per shard. URL and SQLite entries
+// inherit the connection's settings, so DB_OPTIONS and TLS apply to them;
+// driver DSNs are used as written.
+func (c *connection) shardConnections() ([]*connection, error) {
+	var shards []*connection
+	seen := make(map[string]int)
+
+	for i, entry := range c.Shards {
+		shard := *c
+		shard.DB = nil
+		shard.Shards = nil
+
+		if strings.Contains(entry, "://") {
+			shard.URL = entry
+			if err := shard.applyURL(); err != nil {
+				return nil, fmt.Errorf("shard %d: %w", i+1, err)
+			}
+		} else if shard.Driver == "sqlite" || shard.Driver == "sqlite3" {
+			shard.URL = ""
+			shard.Database = entry
+		} else {
+			shard.URL = ""
+			shard.rawDSN = entry
+		}
+
+		shard.Name = shard.shardName(i)
+		seen[shard.Name]++
+		if seen[shard.Name] > 1 {
+			shard.Name = fmt.Sprintf("%s#%d", shard.Name, seen[shard.Name])
+		}
+		shards = append(shards, &shard)
+	}
+
+	return shards, nil
+}
+
+// shardName identifies a shard without its credentials.
+func (c *connection) shardName(index int) string {
+	if c.rawDSN == "" {
+		if c.Driver == "sqlite" || c.Driver == "sqlite3" {
+			return c.Database
+		}
+		return fmt.Sprintf("%s:%s/%s", c.Host, c.Port, c.Database)
+	}
+
+	if c.Driver == "mysql" {
+		if cfg, err := mysql.ParseDSN(c.rawDSN); err == nil {
+			return cfg.Addr + "/" + cfg.DBName
+		}
+	}
+	return fmt.Sprintf("shard-%d", index+1)
+}
+
+// splitShards splits DB_SHARDS on newlines and commas. A comma in a DSN's
+// parameters, as in charset=utf8mb4,utf8, does not start a new shard.
+func splitShards(value string) []string {
+	var shards []string
+	for _, line := range strings.Split(value, "\n") {
+		first := len(shards)
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if n := len(shards); n > first && continuesDSN(shards[n-1], part) {
+				shards[n-1] += "," + part
+				continue
+			}
+			shards = append(shards, part)
+		}
+	}
+	return shards
+}
+
+// continuesDSN reports whether part is more of entry's parameters rather
+// than the start of another URL, user@host DSN or file path.
+func continuesDSN(entry, part string) bool {
+	return strings.Contains(entry, "?") && !strings.Contains(part, "://") &&
+		!strings.Contains(part, "@") && !strings.HasPrefix(part, "/")
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hymns/go-artisan/migration"
+	"github.com/lib/pq"
+)
+
+// isTenantTemplate reports whether the connection only names tenant
+// databases, so there is no shared database to connect to.
+func (c *connection) isTenantTemplate() bool {
+	return strings.Contains(c.Database, "{tenant}")
+}
+
+// hasTenantFlags reports whether a command should run in tenant mode.
+func hasTenantFlags(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--tenants=") || strings.HasPrefix(arg, "--tenants-query=") {
+			return true
+		}
+	}
+	return false
+}
+
+func handleTenants(conn *connection, command string, args []string) {
+	if len(conn.Shards) > 0 {
+		color.Red("✗ --tenants cannot be used with a sharded connection")
+		os.Exit(1)
+	}
+
+	var tenantsFile, tenantsQuery string
+	opts := migration.TenantOptions{Concurrency: 1}
+
+	// Parse --tenants, --tenants-query, --concurrency and --continue-on-error flags
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--tenants=") {
+			tenantsFile = strings.TrimPrefix(arg, "--tenants=")
+		} else if strings.HasPrefix(arg, "--tenants-query=") {
+			tenantsQuery = strings.TrimPrefix(arg, "--tenants-query=")
+		} else if strings.HasPrefix(arg, "--concurrency=") {
+			concurrencyStr := strings.TrimPrefix(arg, "--concurrency=")
+			if s, err := fmt.Sscanf(concurrencyStr, "%d", &opts.Concurrency); err != nil || s != 1 || opts.Concurrency < 1 {
+				color.Red("✗ Invalid --concurrency value: %s", concurrencyStr)
+				os.Exit(1)
+			}
+		} else if arg == "--continue-on-error" {
+			opts.ContinueOnError = true
+		} else if strings.HasPrefix(arg, "--step=") || strings.HasPrefix(arg, "--batch=") || strings.HasPrefix(arg, "--path=") {
+			// Tenant rollbacks always revert the last batch
+			color.Red("✗ %s is not supported with --tenants", strings.SplitN(arg, "=", 2)[0])
+			os.Exit(1)
+		}
+	}
+
+	var tenants []string
+	var err error
+	if tenantsFile != "" {
+		tenants, err = readTenantsFile(tenantsFile)
+	} else if conn.DB == nil {
+		err = fmt.Errorf("--tenants-query needs a database name without a {tenant} placeholder")
+	} else {
+		tenants, err = queryTenants(conn.DB, tenantsQuery)
+	}
+	if err != nil {
+		color.Red("✗ Failed to load tenants: %v", err)
+		os.Exit(1)
+	}
+	if len(tenants) == 0 {
+		color.Yellow("No tenants found.")
+		return
+	}
+
+	if command != "migrate:status" && !confirmToProceed(args) {
+		os.Exit(1)
+	}
+
+	opts.Connect = conn.tenantConnector()
+	m := conn.migration()
+	selectMigrations(m, args)
+	m.AllowIrreversible = allowIrreversible(args)
+
+	color.Cyan("Running %s for %d tenant(s) (concurrency %d)...\n", command, len(tenants), opts.Concurrency)
+
+	var results []migration.TenantResult
+	switch command {
+	case "migrate", "db:migrate":
+		results = m.MigrateTenants(conn.MigrationsPath, tenants, opts)
+	case "migrate:rollback", "db:rollback":
+		results = m.RollbackTenants(conn.MigrationsPath, tenants, opts)
+	case "migrate:status":
+		results = m.StatusTenants(conn.MigrationsPath, tenants, opts)
+	default:
+		color.Red("✗ --tenants is only supported by migrate, migrate:rollback and migrate:status")
+		os.Exit(1)
+	}
+
+	if printTenantResults(results) > 0 {
+		os.Exit(1)
+	}
+}
+
+// resultRow is one tenant or shard in a results table.
+type resultRow struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+	Statuses []migration.MigrationStatus
+
+	// Drift marks a shard whose applied migrations differ from the others
+	Drift bool
+}
+
+func printTenantResults(results []migration.TenantResult) int {
+	rows := make([]resultRow, len(results))
+	for i, result := range results {
+		rows[i] = resultRow{Name: result.Tenant, Err: result.Err, Duration: result.Duration, Statuses: result.Statuses}
+	}
+	return printResults("Tenant", rows)
+}
+
+// printResults prints one row per tenant or shard and returns how many failed.
+func printResults(noun string, rows []resultRow) int {
+	color.Cyan("\n%s Results:\n", noun)
+	color.White("%-30s %-10s %-12s %s\n", noun, "Result", "Duration", "Details")
+	color.White("%s\n", strings.Repeat("-", 80))
+
+	failed := 0
+	for _, row := range rows {
+		fmt.Printf("%-30s ", row.Name)
+		switch {
+		case errors.Is(row.Err, migration.ErrTenantSkipped), errors.Is(row.Err, migration.ErrShardSkipped):
+			color.New(color.FgYellow).Printf("%-10s ", "SKIPPED")
+			fmt.Printf("%-12s\n", "-")
+			continue
+		case row.Err != nil:
+			failed++
+			color.New(color.FgRed).Printf("%-10s ", "FAILED")
+		case row.Drift:
+			color.New(color.FgYellow).Printf("%-10s ", "DRIFT")
+		default:
+			color.New(color.FgGreen).Printf("%-10s ", "OK")
+		}
+
+		details := ""
+		if row.Err != nil {
+			details = row.Err.Error()
+		} else if row.Statuses != nil {
+			pending := 0
+			for _, status := range row.Statuses {
+				if !status.Migrated {
+					pending++
+				}
+			}
+			details = fmt.Sprintf("%d migrated, %d pending", len(row.Statuses)-pending, pending)
+		}
+		fmt.Printf("%-12s %s\n", row.Duration.Round(time.Millisecond), details)
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		color.Red("✗ %d of %d %s(s) failed", failed, len(rows), strings.ToLower(noun))
+	} else {
+		color.Green("✓ All %ss completed", strings.ToLower(noun))
+	}
+	return failed
+}
+
+// readTenantsFile reads one tenant per line. Blank lines and # comments are
+// ignored.
+func readTenantsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var tenants []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tenants = append(tenants, line)
+	}
+	return tenants, scanner.Err()
+}
+
+// queryTenants runs a query returning one tenant name per row, e.g.
+// SELECT schema_name FROM information_schema.schemata WHERE schema_name LIKE 'tenant_%'.
+func queryTenants(db *sql.DB, query string) ([]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []string
+	for rows.Next() {
+		var tenant string
+		if err := rows.Scan(&tenant); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// tenantConnector opens one pool per tenant. A {tenant} placeholder in the
+// database name selects a database or SQLite file per tenant. Otherwise
+// PostgreSQL tenants are schemas and other drivers use the tenant as the
+// database name.
+func (c *connection) tenantConnector() func(string) (*sql.DB, error) {
+	return func(tenant string) (*sql.DB, error) {
+		t := *c
+		t.DB = nil
+
+		switch {
+		case c.isTenantTemplate():
+			t.Database = strings.ReplaceAll(c.Database, "{tenant}", tenant)
+		case c.Driver == "postgres":
+			if _, err := c.DB.Exec("CREATE SCHEMA IF NOT EXISTS " + pq.QuoteIdentifier(tenant)); err != nil {
+				return nil, fmt.Errorf("failed to create schema: %w", err)
+			}
+			options, err := url.ParseQuery(c.Options)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DB_OPTIONS: %w", err)
+			}
+			options.Set("search_path", tenant)
+			t.Options = options.Encode()
+		case c.Driver == "sqlite" || c.Driver == "sqlite3":
+			return nil, fmt.Errorf("SQLite tenants need a {tenant} placeholder in the database path")
+		default:
+			t.Database = tenant
+		}
+
+		if err := t.open(); err != nil {
+			return nil, err
+		}
+		return t.DB, nil
+	}
+}
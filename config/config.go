@@ -0,0 +1,260 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// envPattern only matches the ${NAME} form so that passwords containing a
+// bare $ are left alone.
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// FileNames are the config files looked for in each directory, in order.
+var FileNames = []string{"artisan.yaml", "artisan.yml", "artisan.toml"}
+
+type Connection struct {
+	Driver         string `yaml:"driver" toml:"driver"`
+	URL            string `yaml:"url" toml:"url"`
+	Host           string `yaml:"host" toml:"host"`
+	Port           int    `yaml:"port" toml:"port"`
+	Socket         string `yaml:"socket" toml:"socket"`
+	Database       string `yaml:"database" toml:"database"`
+	Username       string `yaml:"username" toml:"username"`
+	Password       string `yaml:"password" toml:"password"`
+	Charset        string `yaml:"charset" toml:"charset"`
+	SSLMode        string `yaml:"sslmode" toml:"sslmode"`
+	SSLRootCert    string `yaml:"sslrootcert" toml:"sslrootcert"`
+	Options        string `yaml:"options" toml:"options"`
+	MigrationsPath string `yaml:"migrations_path" toml:"migrations_path"`
+	SeedersPath    string `yaml:"seeders_path" toml:"seeders_path"`
+
+	// Shards are URLs or DSNs of databases migrated as this one connection
+	Shards []string `yaml:"shards" toml:"shards"`
+
+	// Retries re-run a migration or seeder after a transient error
+	Retries      int    `yaml:"retries" toml:"retries"`
+	RetryBackoff string `yaml:"retry_backoff" toml:"retry_backoff"`
+
+	// Role and Init run before every migration and seeder
+	Role string   `yaml:"role" toml:"role"`
+	Init []string `yaml:"init" toml:"init"`
+}
+
+// File is an artisan.yaml or artisan.toml. Top-level connection settings
+// configure the default connection; Connections adds named ones.
+type File struct {
+	Env       string `yaml:"env" toml:"env"`
+	Default   string `yaml:"default" toml:"default"`
+	StubsPath string `yaml:"stubs_path" toml:"stubs_path"`
+
+	Connection  `yaml:",inline"`
+	Connections map[string]Connection `yaml:"connections" toml:"connections"`
+
+	// Path is the file that was loaded.
+	Path string `yaml:"-" toml:"-"`
+
+	// order keeps connections in the order they appear in the file
+	order []string
+}
+
+// Find walks up from dir and returns the first config file found, or ""
+// when there is none.
+func Find(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		for _, name := range FileNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	f := &File{Path: path}
+
+	if strings.HasSuffix(path, ".toml") {
+		meta, err := toml.Decode(string(data), f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for _, key := range meta.Keys() {
+			if len(key) == 2 && key[0] == "connections" {
+				f.order = append(f.order, key[1])
+			}
+		}
+	} else {
+		if err := yaml.Unmarshal(data, f); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err == nil {
+			f.order = yamlConnectionOrder(&node)
+		}
+	}
+
+	return f, nil
+}
+
+func yamlConnectionOrder(node *yaml.Node) []string {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "connections" || node.Content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		var names []string
+		connections := node.Content[i+1].Content
+		for j := 0; j < len(connections); j += 2 {
+			names = append(names, connections[j].Value)
+		}
+		return names
+	}
+
+	return nil
+}
+
+// Setting is one config value mapped to the environment variable that the
+// rest of artisan reads.
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// Settings flattens the file into environment variables: DB_* for the
+// top-level connection, <NAME>_DB_* for named ones and DB_CONNECTIONS for
+// their order. ${NAME} references are replaced from the environment and
+// relative paths are resolved against the file's directory.
+func (f *File) Settings() []Setting {
+	var settings []Setting
+	add := func(key, value string) {
+		if value != "" {
+			settings = append(settings, Setting{Key: key, Value: expandEnv(value)})
+		}
+	}
+
+	add("APP_ENV", f.Env)
+	add("DB_CONNECTION", f.Default)
+	add("STUBS_PATH", f.resolve(valueOr(f.StubsPath, "./database/stubs")))
+
+	// Default paths are relative to the project root, not the working directory
+	top := f.Connection
+	top.MigrationsPath = valueOr(top.MigrationsPath, "./database/migrations")
+	top.SeedersPath = valueOr(top.SeedersPath, "./database/seeders")
+	settings = append(settings, f.connectionSettings("", top)...)
+
+	names := f.connectionNames()
+	if len(names) > 0 {
+		add("DB_CONNECTIONS", strings.Join(names, ","))
+	}
+	for _, name := range names {
+		settings = append(settings, f.connectionSettings(strings.ToUpper(name)+"_", f.Connections[name])...)
+	}
+
+	return settings
+}
+
+func (f *File) connectionSettings(prefix string, c Connection) []Setting {
+	var settings []Setting
+	add := func(key, value string) {
+		if value != "" {
+			settings = append(settings, Setting{Key: prefix + key, Value: expandEnv(value)})
+		}
+	}
+
+	add("DB_DRIVER", c.Driver)
+	add("DATABASE_URL", c.URL)
+	add("DB_HOST", c.Host)
+	if c.Port != 0 {
+		add("DB_PORT", strconv.Itoa(c.Port))
+	}
+	add("DB_SOCKET", c.Socket)
+	database := c.Database
+	if c.Driver == "sqlite" || c.Driver == "sqlite3" {
+		database = f.resolve(database)
+	}
+	add("DB_DATABASE", database)
+	add("DB_USERNAME", c.Username)
+	add("DB_PASSWORD", c.Password)
+	add("DB_CHARSET", c.Charset)
+	add("DB_SSLMODE", c.SSLMode)
+	add("DB_SSLROOTCERT", f.resolve(c.SSLRootCert))
+	add("DB_OPTIONS", c.Options)
+	add("MIGRATIONS_PATH", f.resolve(c.MigrationsPath))
+	add("SEEDERS_PATH", f.resolve(c.SeedersPath))
+	add("DB_SHARDS", strings.Join(c.Shards, ","))
+	if c.Retries > 0 {
+		add("DB_RETRIES", strconv.Itoa(c.Retries))
+	}
+	add("DB_RETRY_BACKOFF", c.RetryBackoff)
+	add("DB_ROLE", c.Role)
+	add("DB_INIT", strings.Join(c.Init, "; "))
+
+	return settings
+}
+
+func (f *File) connectionNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range f.order {
+		if _, ok := f.Connections[name]; ok && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	for name := range f.Connections {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (f *File) resolve(path string) string {
+	path = expandEnv(path)
+	if path == "" || filepath.IsAbs(path) || f.Path == "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(f.Path), path)
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func expandEnv(value string) string {
+	return envPattern.ReplaceAllStringFunc(value, func(match string) string {
+		return os.Getenv(match[2 : len(match)-1])
+	})
+}
@@ -0,0 +1,239 @@
+package dashboard
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/hymns/go-artisan/health"
+	"github.com/hymns/go-artisan/migration"
+	"github.com/hymns/go-artisan/seeder"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Dashboard is a self-contained web UI for migrations and seeders. All assets
+// are embedded so it works without network access.
+type Dashboard struct {
+	Migration      *migration.Migration
+	Seeder         *seeder.Seeder
+	MigrationsPath string
+	SeedersPath    string
+
+	token string
+	mu    sync.Mutex
+	mux   *http.ServeMux
+}
+
+type batchView struct {
+	Batch      int
+	Migrations []string
+}
+
+type sectionView struct {
+	Kind string
+	Text string
+}
+
+type pageView struct {
+	Token      string
+	Migrations []migration.MigrationStatus
+	Seeders    []seeder.SeederStatus
+	Batches    []batchView
+	Pending    int
+	Output     string
+	Error      string
+}
+
+type fileView struct {
+	Token    string
+	Name     string
+	Sections []sectionView
+	Error    string
+}
+
+func New(m *migration.Migration, s *seeder.Seeder, migrationsPath, seedersPath string) *Dashboard {
+	d := &Dashboard{
+		Migration:      m,
+		Seeder:         s,
+		MigrationsPath: migrationsPath,
+		SeedersPath:    seedersPath,
+		token:          newToken(),
+	}
+
+	d.mux = http.NewServeMux()
+	d.mux.HandleFunc("/", d.handleIndex)
+	d.mux.HandleFunc("/file", d.handleFile)
+	d.mux.HandleFunc("/actions/migrate", d.handleAction(func() error { return d.Migration.Migrate(d.MigrationsPath) }))
+	d.mux.HandleFunc("/actions/rollback", d.handleAction(func() error { return d.Migration.Rollback(d.MigrationsPath) }))
+	d.mux.HandleFunc("/actions/seed", d.handleAction(func() error { return d.Seeder.RunWithTracking(d.SeedersPath) }))
+	d.mux.Handle("/api/", http.StripPrefix("/api", health.NewHandler(m, s, migrationsPath, seedersPath)))
+
+	return d
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mux.ServeHTTP(w, r)
+}
+
+func (d *Dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	d.render(w, "", "")
+}
+
+func (d *Dashboard) render(w http.ResponseWriter, output, errText string) {
+	view := pageView{Token: d.token, Output: output, Error: errText}
+
+	migrations, err := d.Migration.Status(d.MigrationsPath)
+	if err != nil {
+		view.Error = strings.TrimSpace(view.Error + "\n" + err.Error())
+	}
+	view.Migrations = migrations
+
+	batches := make(map[int][]string)
+	for _, status := range migrations {
+		if status.Migrated {
+			batches[status.Batch] = append(batches[status.Batch], status.Name)
+		} else {
+			view.Pending++
+		}
+	}
+	for batch, names := range batches {
+		view.Batches = append(view.Batches, batchView{Batch: batch, Migrations: names})
+	}
+	sort.Slice(view.Batches, func(i, j int) bool { return view.Batches[i].Batch > view.Batches[j].Batch })
+
+	if d.Seeder != nil {
+		seeders, err := d.Seeder.Status(d.SeedersPath)
+		if err != nil {
+			view.Error = strings.TrimSpace(view.Error + "\n" + err.Error())
+		}
+		view.Seeders = seeders
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	templates.ExecuteTemplate(w, "index.html", view)
+}
+
+func (d *Dashboard) handleFile(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	kind := r.URL.Query().Get("type")
+
+	dir := d.MigrationsPath
+	if kind == "seeder" {
+		dir = d.SeedersPath
+	}
+
+	view := fileView{Token: d.token, Name: name}
+
+	// Only plain file names inside the configured directories are served
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		http.Error(w, "invalid file name", http.StatusBadRequest)
+		return
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		view.Error = err.Error()
+	} else {
+		view.Sections = splitSections(string(content))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	templates.ExecuteTemplate(w, "file.html", view)
+}
+
+func (d *Dashboard) handleAction(run func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.FormValue("token") != d.token {
+			http.Error(w, "invalid token", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/actions/seed" && d.Seeder == nil {
+			http.Error(w, "seeders are not configured", http.StatusNotFound)
+			return
+		}
+
+		output, err := d.capture(run)
+		errText := ""
+		if err != nil {
+			errText = err.Error()
+		}
+		d.render(w, output, errText)
+	}
+}
+
+// capture runs fn while copying the colored console output into a buffer so
+// it can be shown in the browser. Actions are serialized.
+func (d *Dashboard) capture(fn func() error) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var buf bytes.Buffer
+	original := color.Output
+	color.Output = io.MultiWriter(original, &buf)
+	defer func() { color.Output = original }()
+
+	err := fn()
+	return ansiPattern.ReplaceAllString(buf.String(), ""), err
+}
+
+// splitSections breaks a migration file into header, UP and DOWN parts for
+// highlighting. Files without markers (seeders) are returned as one section.
+func splitSections(text string) []sectionView {
+	var sections []sectionView
+	kind := "header"
+	var current []string
+
+	flush := func() {
+		if len(current) > 0 {
+			sections = append(sections, sectionView{Kind: kind, Text: strings.Join(current, "\n")})
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		marker := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(marker, "--UP"):
+			flush()
+			kind = "up"
+		case strings.HasPrefix(marker, "--DOWN"):
+			flush()
+			kind = "down"
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	return sections
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
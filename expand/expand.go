@@ -0,0 +1,227 @@
+package expand
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// variablePattern matches ${NAME} and ${NAME:-default}, and the escaped
+// form $${NAME}.
+var variablePattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// macroPattern matches {{name}}, and the escaped form ${{name}}.
+var macroPattern = regexp.MustCompile(`\$?\{\{\s*([a-z_]+)\s*\}\}`)
+
+// macros maps each {{name}} to its expansion per driver.
+var macros = map[string]map[string]string{
+	"id": {
+		"mysql":     "INTEGER PRIMARY KEY AUTO_INCREMENT",
+		"postgres":  "SERIAL PRIMARY KEY",
+		"sqlite":    "INTEGER PRIMARY KEY AUTOINCREMENT",
+		"sqlserver": "INT IDENTITY(1,1) PRIMARY KEY",
+	},
+	"timestamp": {
+		"mysql":     "TIMESTAMP",
+		"postgres":  "TIMESTAMP",
+		"sqlite":    "TIMESTAMP",
+		"sqlserver": "DATETIME",
+	},
+	"now": {
+		"mysql":     "CURRENT_TIMESTAMP",
+		"postgres":  "CURRENT_TIMESTAMP",
+		"sqlite":    "CURRENT_TIMESTAMP",
+		"sqlserver": "GETDATE()",
+	},
+	"uuid": {
+		"mysql":     "CHAR(36)",
+		"postgres":  "UUID",
+		"sqlite":    "CHAR(36)",
+		"sqlserver": "UNIQUEIDENTIFIER",
+	},
+	"bool": {
+		"mysql":     "TINYINT(1)",
+		"postgres":  "BOOLEAN",
+		"sqlite":    "INTEGER",
+		"sqlserver": "BIT",
+	},
+	"json": {
+		"mysql":     "JSON",
+		"postgres":  "JSONB",
+		"sqlite":    "TEXT",
+		"sqlserver": "NVARCHAR(MAX)",
+	},
+}
+
+// Macros returns the supported macro names.
+func Macros() []string {
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SQL replaces ${VAR} references and {{macro}}s in text. Variables are
+// looked up in vars first, then the environment; an undefined variable
+// without a :- default is an error. A leading $ escapes either one, so
+// $${VAR} and ${{macro}} are written as ${VAR} and {{macro}}. String
+// literals and comments are left untouched.
+func SQL(text, driver string, vars map[string]string) (string, error) {
+	driver = canonicalDriver(driver)
+
+	var err error
+	text = mapCode(text, driver, func(code string) string {
+		if err != nil {
+			return code
+		}
+
+		code = variablePattern.ReplaceAllStringFunc(code, func(match string) string {
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+			parts := variablePattern.FindStringSubmatch(match)
+			if value, ok := lookup(parts[1], vars); ok {
+				return value
+			}
+			if parts[2] != "" {
+				return parts[3]
+			}
+			if err == nil {
+				err = fmt.Errorf("undefined variable ${%s}", parts[1])
+			}
+			return match
+		})
+		if err != nil {
+			return code
+		}
+
+		return macroPattern.ReplaceAllStringFunc(code, func(match string) string {
+			if strings.HasPrefix(match, "$") {
+				return match[1:]
+			}
+			name := macroPattern.FindStringSubmatch(match)[1]
+			expansion, ok := macros[name][driver]
+			if !ok {
+				if err == nil {
+					err = fmt.Errorf("unknown macro {{%s}}", name)
+				}
+				return match
+			}
+			return expansion
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return text, nil
+}
+
+// Variables returns the sorted names of the variables referenced outside
+// string literals and comments.
+func Variables(text, driver string) []string {
+	seen := make(map[string]bool)
+	var names []string
+
+	mapCode(text, canonicalDriver(driver), func(code string) string {
+		for _, parts := range variablePattern.FindAllStringSubmatch(code, -1) {
+			if strings.HasPrefix(parts[0], "$$") || seen[parts[1]] {
+				continue
+			}
+			seen[parts[1]] = true
+			names = append(names, parts[1])
+		}
+		return code
+	})
+
+	sort.Strings(names)
+	return names
+}
+
+// mapCode passes each stretch of text outside string literals and comments
+// through fn, and leaves the rest as written. A ${...} or {{...}} reference
+// is kept whole, as a :- default may hold quotes.
+func mapCode(text, driver string, fn func(code string) string) string {
+	var out strings.Builder
+	start := 0
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		var end int
+
+		switch {
+		case strings.HasPrefix(rest, "${"), strings.HasPrefix(rest, "{{"):
+			closing := "}"
+			if rest[0] == '{' {
+				closing = "}}"
+			}
+			if n := strings.Index(rest[2:], closing); n != -1 {
+				i += 2 + n + len(closing)
+			} else {
+				i += 2
+			}
+			continue
+		case strings.HasPrefix(rest, "--"):
+			end = strings.IndexByte(rest, '\n')
+		case strings.HasPrefix(rest, "/*"):
+			if end = strings.Index(rest[2:], "*/"); end != -1 {
+				end += 4
+			}
+		case rest[0] == '\'', rest[0] == '"' && driver == "mysql":
+			end = closingQuote(rest, driver == "mysql")
+		default:
+			i++
+			continue
+		}
+
+		if end == -1 {
+			end = len(rest)
+		}
+		out.WriteString(fn(text[start:i]))
+		out.WriteString(rest[:end])
+		i += end
+		start = i
+	}
+
+	out.WriteString(fn(text[start:]))
+	return out.String()
+}
+
+// closingQuote returns the length of the quoted literal at the start of s,
+// or -1 when it is not closed. MySQL also escapes quotes with a backslash.
+func closingQuote(s string, backslash bool) int {
+	for i := 1; i < len(s); i++ {
+		if backslash && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == s[0] {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func lookup(name string, vars map[string]string) (string, bool) {
+	if value, ok := vars[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+func canonicalDriver(driver string) string {
+	switch driver {
+	case "sqlite3":
+		return "sqlite"
+	case "mssql":
+		return "sqlserver"
+	case "postgres", "sqlite", "sqlserver":
+		return driver
+	default:
+		return "mysql"
+	}
+}
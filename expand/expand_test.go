@@ -0,0 +1,125 @@
+package expand
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSQL(t *testing.T) {
+	vars := map[string]string{"DB_SCHEMA": "app", "OWNER": "migrator"}
+
+	tests := []struct {
+		name   string
+		driver string
+		text   string
+		want   string
+	}{
+		{
+			name: "variables and macros",
+			text: "CREATE TABLE ${DB_SCHEMA}.users (id {{id}}, created_at {{timestamp}} DEFAULT {{now}})",
+			want: "CREATE TABLE app.users (id INTEGER PRIMARY KEY AUTO_INCREMENT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)",
+		},
+		{
+			name:   "driver specific macro",
+			driver: "postgres",
+			text:   "id {{id}}, data {{json}}",
+			want:   "id SERIAL PRIMARY KEY, data JSONB",
+		},
+		{
+			name: "default value",
+			text: "CREATE TABLE t (id INT) ${TABLE_OPTIONS_UNSET:-ENGINE=InnoDB}",
+			want: "CREATE TABLE t (id INT) ENGINE=InnoDB",
+		},
+		{
+			name: "default value with quotes",
+			text: "CREATE TABLE t (id INT) ${TABLE_OPTIONS_UNSET:-COMMENT='x'} OWNER ${OWNER}",
+			want: "CREATE TABLE t (id INT) COMMENT='x' OWNER migrator",
+		},
+		{
+			name: "escapes",
+			text: "SELECT $${DB_SCHEMA}, ${{now}}",
+			want: "SELECT ${DB_SCHEMA}, {{now}}",
+		},
+		{
+			name: "single quoted literal",
+			text: "INSERT INTO users (name) VALUES ('Hello {{name}} ${UNDEFINED}') -- ${DB_SCHEMA}",
+			want: "INSERT INTO users (name) VALUES ('Hello {{name}} ${UNDEFINED}') -- ${DB_SCHEMA}",
+		},
+		{
+			name: "doubled quote inside literal",
+			text: "SELECT 'it''s {{name}}', ${OWNER}",
+			want: "SELECT 'it''s {{name}}', migrator",
+		},
+		{
+			name: "mysql backslash escape",
+			text: `SELECT 'it\'s {{name}}', "{{name}}", ${OWNER}`,
+			want: `SELECT 'it\'s {{name}}', "{{name}}", migrator`,
+		},
+		{
+			name:   "postgres double quotes are identifiers",
+			driver: "postgres",
+			text:   `SELECT * FROM "${DB_SCHEMA}".users`,
+			want:   `SELECT * FROM "app".users`,
+		},
+		{
+			name: "line comment",
+			text: "-- uses ${UNDEFINED}\nSELECT ${OWNER} -- trailing {{name}}\nSELECT 1",
+			want: "-- uses ${UNDEFINED}\nSELECT migrator -- trailing {{name}}\nSELECT 1",
+		},
+		{
+			name: "block comment",
+			text: "SELECT /* {{name}}\n${UNDEFINED} */ ${OWNER}",
+			want: "SELECT /* {{name}}\n${UNDEFINED} */ migrator",
+		},
+		{
+			name: "unclosed literal",
+			text: "SELECT ${OWNER}, 'oops {{name}}",
+			want: "SELECT migrator, 'oops {{name}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SQL(tt.text, tt.driver, vars)
+			if err != nil {
+				t.Fatalf("SQL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SQL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"undefined variable", "SELECT * FROM ${ARTISAN_TEST_UNDEFINED}.users", "undefined variable ${ARTISAN_TEST_UNDEFINED}"},
+		{"unknown macro", "CREATE TABLE t (id {{serial}})", "unknown macro {{serial}}"},
+		{"variables before macros", "SELECT {{nope}}, ${ARTISAN_TEST_UNDEFINED}", "undefined variable ${ARTISAN_TEST_UNDEFINED}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SQL(tt.text, "mysql", nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("SQL() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariables(t *testing.T) {
+	text := "CREATE TABLE ${DB_SCHEMA}.t (${COL:-id} INT) -- ${IN_COMMENT}\n" +
+		"INSERT INTO t VALUES ('${IN_LITERAL}', $${ESCAPED}, ${DB_SCHEMA})"
+
+	got := Variables(text, "mysql")
+	want := []string{"COL", "DB_SCHEMA"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hymns/go-artisan/migration"
+	"github.com/hymns/go-artisan/seeder"
+)
+
+// Handler exposes migration and seeder status as JSON. Mount it under a
+// prefix on an existing mux:
+//
+//	mux.Handle("/artisan/", http.StripPrefix("/artisan", health.NewHandler(m, s, migrationsPath, seedersPath)))
+//
+// Routes:
+//
+//	GET /migrations  migration status (same data as Migration.Status)
+//	GET /seeders     seeder status (same data as Seeder.Status)
+//	GET /ready       200 when no expand migration is pending and the lock is free, 503 otherwise
+type Handler struct {
+	Migration      *migration.Migration
+	Seeder         *seeder.Seeder
+	MigrationsPath string
+	SeedersPath    string
+
+	mux *http.ServeMux
+}
+
+type migrationResponse struct {
+	Name     string   `json:"name"`
+	Migrated bool     `json:"migrated"`
+	Batch    int      `json:"batch,omitempty"`
+	Phase    string   `json:"phase"`
+	Tags     []string `json:"tags,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type seederResponse struct {
+	Name   string `json:"name"`
+	Seeded bool   `json:"seeded"`
+}
+
+type lockResponse struct {
+	Locked   bool   `json:"locked"`
+	LockedAt string `json:"locked_at,omitempty"`
+	LockedBy string `json:"locked_by,omitempty"`
+}
+
+type readyResponse struct {
+	Ready           bool         `json:"ready"`
+	CurrentVersion  string       `json:"current_version"`
+	Batch           int          `json:"batch"`
+	Pending         int          `json:"pending"`
+	PendingContract int          `json:"pending_contract,omitempty"`
+	PendingSeeders  int          `json:"pending_seeders,omitempty"`
+	Lock            lockResponse `json:"lock"`
+	Error           string       `json:"error,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler builds a Handler. s may be nil when seeders are not used.
+func NewHandler(m *migration.Migration, s *seeder.Seeder, migrationsPath, seedersPath string) *Handler {
+	h := &Handler{
+		Migration:      m,
+		Seeder:         s,
+		MigrationsPath: migrationsPath,
+		SeedersPath:    seedersPath,
+	}
+
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("/migrations", h.handleMigrations)
+	h.mux.HandleFunc("/seeders", h.handleSeeders)
+	h.mux.HandleFunc("/ready", h.handleReady)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleMigrations(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.Migration.Status(h.MigrationsPath)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	response := make([]migrationResponse, 0, len(statuses))
+	for _, status := range statuses {
+		response = append(response, migrationResponse{
+			Name:     status.Name,
+			Migrated: status.Migrated,
+			Batch:    status.Batch,
+			Phase:    status.Phase,
+			Tags:     status.Tags,
+			Warnings: status.Warnings,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleSeeders(w http.ResponseWriter, r *http.Request) {
+	if h.Seeder == nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "seeders are not configured"})
+		return
+	}
+
+	statuses, err := h.Seeder.Status(h.SeedersPath)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	response := make([]seederResponse, 0, len(statuses))
+	for _, status := range statuses {
+		response = append(response, seederResponse{
+			Name:   status.Name,
+			Seeded: status.Seeded,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
+	response := readyResponse{}
+
+	statuses, err := h.Migration.Status(h.MigrationsPath)
+	if err != nil {
+		response.Error = err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+
+	for _, status := range statuses {
+		// Contract migrations wait for the new code, so they do not fail readiness
+		if !status.Migrated && status.Phase == migration.PhaseContract {
+			response.PendingContract++
+			continue
+		}
+		if !status.Migrated {
+			response.Pending++
+			continue
+		}
+		response.CurrentVersion = status.Name
+		if status.Batch > response.Batch {
+			response.Batch = status.Batch
+		}
+	}
+
+	lock, err := h.Migration.LockStatus()
+	if err != nil {
+		response.Error = err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+	response.Lock = lockResponse{
+		Locked:   lock.Locked,
+		LockedAt: lock.LockedAt,
+		LockedBy: lock.LockedBy,
+	}
+
+	// Pending seeders are reported but do not fail readiness
+	if h.Seeder != nil {
+		if seeders, err := h.Seeder.Status(h.SeedersPath); err == nil {
+			for _, status := range seeders {
+				if !status.Seeded {
+					response.PendingSeeders++
+				}
+			}
+		}
+	}
+
+	response.Ready = response.Pending == 0 && !response.Lock.Locked
+
+	code := http.StatusOK
+	if !response.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, response)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
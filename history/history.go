@@ -0,0 +1,266 @@
+package history
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TableName is the append-only audit table shared by migrations and seeders.
+const TableName = "migration_history"
+
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+	DirectionSeed = "seed"
+	DirectionWipe = "wipe"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeRemoved = "removed"
+)
+
+type Entry struct {
+	ID        int64
+	Migration string
+	Direction string
+	Batch     int
+	Host      string
+	User      string
+	Duration  time.Duration
+	Checksum  string
+	Outcome   string
+	Error     string
+	CreatedAt time.Time
+}
+
+type Filter struct {
+	Migration string // substring match
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+var ensured sync.Map
+
+func EnsureTable(db *sql.DB, driver string) error {
+	var query string
+
+	switch driver {
+	case "postgres":
+		query = `CREATE TABLE IF NOT EXISTS migration_history (
+			id SERIAL PRIMARY KEY,
+			migration VARCHAR(255) NOT NULL,
+			direction VARCHAR(10) NOT NULL,
+			batch INTEGER,
+			host VARCHAR(255),
+			os_user VARCHAR(255),
+			duration_ms BIGINT,
+			checksum VARCHAR(64),
+			outcome VARCHAR(20) NOT NULL,
+			error_message TEXT,
+			created_at TIMESTAMP NOT NULL
+		)`
+	case "sqlite", "sqlite3":
+		query = `CREATE TABLE IF NOT EXISTS migration_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			migration VARCHAR(255) NOT NULL,
+			direction VARCHAR(10) NOT NULL,
+			batch INTEGER,
+			host VARCHAR(255),
+			os_user VARCHAR(255),
+			duration_ms INTEGER,
+			checksum VARCHAR(64),
+			outcome VARCHAR(20) NOT NULL,
+			error_message TEXT,
+			created_at TIMESTAMP NOT NULL
+		)`
+	case "sqlserver", "mssql":
+		query = `IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='migration_history' AND xtype='U')
+			CREATE TABLE migration_history (
+				id INT IDENTITY(1,1) PRIMARY KEY,
+				migration VARCHAR(255) NOT NULL,
+				direction VARCHAR(10) NOT NULL,
+				batch INT,
+				host VARCHAR(255),
+				os_user VARCHAR(255),
+				duration_ms BIGINT,
+				checksum VARCHAR(64),
+				outcome VARCHAR(20) NOT NULL,
+				error_message NVARCHAR(MAX),
+				created_at DATETIME2 NOT NULL
+			)`
+	default: // mysql
+		query = `CREATE TABLE IF NOT EXISTS migration_history (
+			id INTEGER PRIMARY KEY AUTO_INCREMENT,
+			migration VARCHAR(255) NOT NULL,
+			direction VARCHAR(10) NOT NULL,
+			batch INTEGER,
+			host VARCHAR(255),
+			os_user VARCHAR(255),
+			duration_ms BIGINT,
+			checksum VARCHAR(64),
+			outcome VARCHAR(20) NOT NULL,
+			error_message TEXT,
+			created_at DATETIME(6) NOT NULL
+		)`
+	}
+
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	ensured.Store(db, true)
+	return nil
+}
+
+// Record appends an entry. Host, user and timestamp are filled in when empty.
+func Record(db *sql.DB, driver string, e Entry) error {
+	if _, ok := ensured.Load(db); !ok {
+		if err := EnsureTable(db, driver); err != nil {
+			return fmt.Errorf("failed to ensure history table: %w", err)
+		}
+	}
+
+	if e.Host == "" {
+		e.Host = hostname()
+	}
+	if e.User == "" {
+		e.User = Username()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+
+	query := fmt.Sprintf(`INSERT INTO migration_history
+		(migration, direction, batch, host, os_user, duration_ms, checksum, outcome, error_message, created_at)
+		VALUES (%s)`, placeholders(driver, 10))
+
+	_, err := db.Exec(query, e.Migration, e.Direction, e.Batch, e.Host, e.User,
+		e.Duration.Milliseconds(), e.Checksum, e.Outcome, e.Error, e.CreatedAt)
+	return err
+}
+
+func List(db *sql.DB, driver string, filter Filter) ([]Entry, error) {
+	if err := EnsureTable(db, driver); err != nil {
+		return nil, fmt.Errorf("failed to ensure history table: %w", err)
+	}
+
+	var conditions []string
+	var args []interface{}
+
+	if filter.Migration != "" {
+		args = append(args, "%"+filter.Migration+"%")
+		conditions = append(conditions, "migration LIKE "+placeholder(driver, len(args)))
+	}
+	if !filter.Since.IsZero() {
+		args = append(args, filter.Since.UTC())
+		conditions = append(conditions, "created_at >= "+placeholder(driver, len(args)))
+	}
+	if !filter.Until.IsZero() {
+		args = append(args, filter.Until.UTC())
+		conditions = append(conditions, "created_at < "+placeholder(driver, len(args)))
+	}
+
+	// Keep the most recent entries when a limit is given
+	top, limit := "", ""
+	if filter.Limit > 0 {
+		switch driver {
+		case "sqlserver", "mssql":
+			top = fmt.Sprintf("TOP %d ", filter.Limit)
+		default:
+			limit = fmt.Sprintf(" LIMIT %d", filter.Limit)
+		}
+	}
+
+	query := `SELECT ` + top + `id, migration, direction, batch, host, os_user, duration_ms, checksum, outcome, error_message, created_at
+		FROM migration_history`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC" + limit
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var batch, durationMs sql.NullInt64
+		var host, osUser, checksum, errText sql.NullString
+		if err := rows.Scan(&e.ID, &e.Migration, &e.Direction, &batch, &host, &osUser, &durationMs, &checksum, &e.Outcome, &errText, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Batch = int(batch.Int64)
+		e.Host = host.String
+		e.User = osUser.String
+		e.Duration = time.Duration(durationMs.Int64) * time.Millisecond
+		e.Checksum = checksum.String
+		e.Error = errText.String
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Oldest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+// Checksum returns the SHA-256 of a migration or seeder file's contents.
+func Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// Username returns the OS user running artisan, as recorded in the history.
+func Username() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+func placeholder(driver string, position int) string {
+	switch driver {
+	case "postgres":
+		return fmt.Sprintf("$%d", position)
+	case "sqlserver", "mssql":
+		return fmt.Sprintf("@p%d", position)
+	default:
+		return "?"
+	}
+}
+
+func placeholders(driver string, count int) string {
+	list := make([]string, count)
+	for i := range list {
+		list[i] = placeholder(driver, i+1)
+	}
+	return strings.Join(list, ", ")
+}
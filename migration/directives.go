@@ -0,0 +1,325 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrDependencyCycle is returned when migrations depend on each other in a loop.
+var ErrDependencyCycle = errors.New("migration dependency cycle")
+
+// ErrUnknownDependency is returned when @depends names a migration that does
+// not exist.
+var ErrUnknownDependency = errors.New("unknown migration dependency")
+
+// ErrDependencyPending is returned when a migration would run before a
+// dependency that is not applied and not part of the same run, e.g. one
+// outside the selected tags.
+var ErrDependencyPending = errors.New("dependency is pending")
+
+// ErrTimeout is returned when a migration runs longer than its -- @timeout
+// or Migration.Timeout.
+var ErrTimeout = errors.New("migration timed out")
+
+const (
+	// PhaseExpand migrations are additive and run before new code ships.
+	// Migrations without -- @phase are expand migrations.
+	PhaseExpand = "expand"
+
+	// PhaseContract migrations are destructive and run once no deployed
+	// code needs the old schema.
+	PhaseContract = "contract"
+)
+
+// directivePattern matches header comments such as "-- @depends: a, b".
+var directivePattern = regexp.MustCompile(`^--[ \t]*@([A-Za-z_-]+)[ \t]*(?::(.*))?$`)
+
+// directives are the @name lines in a migration's header, before the first
+// section marker.
+type directives struct {
+	depends []string
+	tags    []string
+	phase   string
+	unknown []string
+	invalid []string
+
+	irreversible       bool
+	irreversibleReason string
+
+	// noTransaction runs the UP section outside a transaction, e.g. for
+	// CREATE INDEX CONCURRENTLY. Such migrations are never retried.
+	noTransaction bool
+
+	// set holds "-- @set: lock_timeout = '5s'" values, run as SET statements
+	// before the migration's own.
+	set []string
+
+	// timeout is "-- @timeout: 30s", overriding Migration.Timeout
+	timeout time.Duration
+}
+
+func parseDirectives(text string) directives {
+	var d directives
+
+	if loc := sectionMarker.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]]
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		match := directivePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		switch name := strings.ToLower(match[1]); name {
+		case "depends", "depends-on":
+			for _, dep := range splitDirective(match[2]) {
+				d.depends = append(d.depends, filepath.Base(dep))
+			}
+		case "tags", "tag":
+			d.tags = append(d.tags, splitDirective(match[2])...)
+		case "set":
+			if value := strings.TrimSpace(match[2]); value != "" {
+				d.set = append(d.set, value)
+			} else {
+				d.invalid = append(d.invalid, "@set needs a setting, e.g. -- @set: lock_timeout = '5s'")
+			}
+		case "timeout":
+			if timeout, err := time.ParseDuration(strings.TrimSpace(match[2])); err == nil && timeout > 0 {
+				d.timeout = timeout
+			} else {
+				d.invalid = append(d.invalid, fmt.Sprintf("invalid @timeout %q (use a duration such as 30s)", strings.TrimSpace(match[2])))
+			}
+		case "no-transaction":
+			d.noTransaction = true
+		case "irreversible":
+			d.irreversible = true
+			d.irreversibleReason = strings.TrimSpace(match[2])
+		case "phase":
+			switch phase := strings.ToLower(strings.TrimSpace(match[2])); phase {
+			case PhaseExpand, PhaseContract:
+				d.phase = phase
+			default:
+				d.invalid = append(d.invalid, fmt.Sprintf("invalid @phase %q (use expand or contract)", strings.TrimSpace(match[2])))
+			}
+		default:
+			d.unknown = append(d.unknown, "@"+match[1])
+		}
+	}
+
+	return d
+}
+
+func splitDirective(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+}
+
+// phaseOf returns the migration's phase, expand when none is declared.
+func (d directives) phaseOf() string {
+	if d.phase == "" {
+		return PhaseExpand
+	}
+	return d.phase
+}
+
+// inPhase reports whether d belongs to phase. An empty phase matches both.
+func (d directives) inPhase(phase string) bool {
+	return phase == "" || d.phaseOf() == phase
+}
+
+// hasTag reports whether any of tags is in selected. An empty selection
+// matches everything.
+func hasTag(tags, selected []string) bool {
+	if len(selected) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		for _, want := range selected {
+			if strings.EqualFold(tag, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// migrationFile is a migration on disk with its parsed header.
+type migrationFile struct {
+	path       string
+	name       string
+	directives directives
+}
+
+// orderedMigrationFiles returns the migrations in dependency order. Files
+// without dependencies keep their filename order.
+func (m *Migration) orderedMigrationFiles(path string) ([]migrationFile, error) {
+	paths, err := m.getMigrationFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]migrationFile, len(paths))
+	for i, p := range paths {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", filepath.Base(p), err)
+		}
+		files[i] = migrationFile{path: p, name: filepath.Base(p), directives: parseDirectives(string(content))}
+	}
+
+	return orderMigrations(files)
+}
+
+// orderMigrations sorts files topologically, always taking the earliest
+// filename whose dependencies are done.
+func orderMigrations(files []migrationFile) ([]migrationFile, error) {
+	index := make(map[string]int, len(files))
+	for i, f := range files {
+		index[f.name] = i
+	}
+
+	dependents := make([][]int, len(files))
+	remaining := make([]int, len(files))
+	for i, f := range files {
+		for _, dep := range f.directives.depends {
+			j, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s depends on %s", ErrUnknownDependency, f.name, dep)
+			}
+			dependents[j] = append(dependents[j], i)
+			remaining[i]++
+		}
+	}
+
+	var ready []int
+	for i := range files {
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	ordered := make([]migrationFile, 0, len(files))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		next := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, files[next])
+
+		for _, i := range dependents[next] {
+			remaining[i]--
+			if remaining[i] == 0 {
+				ready = append(ready, i)
+			}
+		}
+	}
+
+	if len(ordered) < len(files) {
+		return nil, fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(findCycle(files, index, remaining), " -> "))
+	}
+
+	return ordered, nil
+}
+
+// findCycle follows dependencies among the unresolved files until a name
+// repeats, and returns the loop.
+func findCycle(files []migrationFile, index map[string]int, remaining []int) []string {
+	start := -1
+	for i := range files {
+		if remaining[i] > 0 {
+			start = i
+			break
+		}
+	}
+
+	seen := make(map[int]int)
+	var path []string
+	for current := start; current >= 0; {
+		if at, ok := seen[current]; ok {
+			return append(path[at:], files[current].name)
+		}
+		seen[current] = len(path)
+		path = append(path, files[current].name)
+
+		next := -1
+		for _, dep := range files[current].directives.depends {
+			if j := index[dep]; remaining[j] > 0 {
+				next = j
+				break
+			}
+		}
+		current = next
+	}
+	return path
+}
+
+// pendingMigrations returns the files to run, in order, for the selected
+// tags and phase. A selected migration may not depend on a pending one that
+// is left out.
+func (m *Migration) pendingMigrations(migrationsPath string, migrated []string, phase string) ([]migrationFile, error) {
+	if phase != "" && phase != PhaseExpand && phase != PhaseContract {
+		return nil, fmt.Errorf("invalid phase %q (use expand or contract)", phase)
+	}
+
+	files, err := m.orderedMigrationFiles(migrationsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]bool)
+	for _, f := range files {
+		if !contains(migrated, f.name) {
+			pending[f.name] = true
+		}
+	}
+
+	var selected []migrationFile
+	chosen := make(map[string]bool)
+	for _, f := range files {
+		if !pending[f.name] || !hasTag(f.directives.tags, m.Tags) || !f.directives.inPhase(phase) {
+			continue
+		}
+		if m.only != nil && !contains(m.only, f.name) {
+			continue
+		}
+		if len(f.directives.invalid) > 0 {
+			return nil, fmt.Errorf("%s: %s", f.name, f.directives.invalid[0])
+		}
+		for _, dep := range f.directives.depends {
+			if pending[dep] && !chosen[dep] {
+				return nil, fmt.Errorf("%w: %s depends on %s", ErrDependencyPending, f.name, dep)
+			}
+		}
+		selected = append(selected, f)
+		chosen[f.name] = true
+	}
+
+	return selected, nil
+}
+
+// dependentsOf lists the migrations whose @depends name target, directly or
+// through other migrations.
+func (m *Migration) dependentsOf(migrationsPath, target string) []string {
+	files, err := m.orderedMigrationFiles(migrationsPath)
+	if err != nil {
+		return nil
+	}
+
+	needed := map[string]bool{target: true}
+	var dependents []string
+	for _, f := range files {
+		for _, dep := range f.directives.depends {
+			if needed[dep] {
+				needed[f.name] = true
+				dependents = append(dependents, f.name)
+				break
+			}
+		}
+	}
+	return dependents
+}
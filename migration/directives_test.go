@@ -0,0 +1,175 @@
+package migration
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testFiles(deps map[string][]string, names ...string) []migrationFile {
+	list := make([]migrationFile, len(names))
+	for i, name := range names {
+		list[i] = migrationFile{name: name, directives: directives{depends: deps[name]}}
+	}
+	return list
+}
+
+func fileNames(files []migrationFile) []string {
+	list := make([]string, len(files))
+	for i, f := range files {
+		list[i] = f.name
+	}
+	return list
+}
+
+func TestOrderMigrations(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		deps  map[string][]string
+		want  []string
+	}{
+		{
+			name:  "no dependencies keeps filename order",
+			files: []string{"2024_01_01_a", "2024_01_02_b", "2024_01_03_c"},
+			want:  []string{"2024_01_01_a", "2024_01_02_b", "2024_01_03_c"},
+		},
+		{
+			name:  "dependency on a later file",
+			files: []string{"2024_01_01_a", "2024_01_02_b", "2024_01_03_c"},
+			deps:  map[string][]string{"2024_01_01_a": {"2024_01_03_c"}},
+			want:  []string{"2024_01_02_b", "2024_01_03_c", "2024_01_01_a"},
+		},
+		{
+			name:  "earliest ready file runs first",
+			files: []string{"2024_01_01_a", "2024_01_02_b", "2024_01_03_c", "2024_01_04_d"},
+			deps: map[string][]string{
+				"2024_01_01_a": {"2024_01_04_d"},
+				"2024_01_02_b": {"2024_01_04_d"},
+			},
+			want: []string{"2024_01_03_c", "2024_01_04_d", "2024_01_01_a", "2024_01_02_b"},
+		},
+		{
+			name:  "released file goes before later ready files",
+			files: []string{"2024_01_01_a", "2024_01_02_b", "2024_01_03_c", "2024_01_04_d"},
+			deps:  map[string][]string{"2024_01_03_c": {"2024_01_02_b"}},
+			want:  []string{"2024_01_01_a", "2024_01_02_b", "2024_01_03_c", "2024_01_04_d"},
+		},
+		{
+			name:  "diamond",
+			files: []string{"2024_01_01_a", "2024_01_02_b", "2024_01_03_c", "2024_01_04_d"},
+			deps: map[string][]string{
+				"2024_01_01_a": {"2024_01_02_b", "2024_01_03_c"},
+				"2024_01_02_b": {"2024_01_04_d"},
+				"2024_01_03_c": {"2024_01_04_d"},
+			},
+			want: []string{"2024_01_04_d", "2024_01_02_b", "2024_01_03_c", "2024_01_01_a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered, err := orderMigrations(testFiles(tt.deps, tt.files...))
+			if err != nil {
+				t.Fatalf("orderMigrations() error = %v", err)
+			}
+			if got := fileNames(ordered); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderMigrations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		deps  map[string][]string
+		err   error
+		want  string
+	}{
+		{
+			name:  "missing dependency",
+			files: []string{"2024_01_01_a", "2024_01_02_b"},
+			deps:  map[string][]string{"2024_01_02_b": {"2024_01_09_missing"}},
+			err:   ErrUnknownDependency,
+			want:  "2024_01_02_b depends on 2024_01_09_missing",
+		},
+		{
+			name:  "self dependency",
+			files: []string{"2024_01_01_a"},
+			deps:  map[string][]string{"2024_01_01_a": {"2024_01_01_a"}},
+			err:   ErrDependencyCycle,
+			want:  "2024_01_01_a -> 2024_01_01_a",
+		},
+		{
+			name:  "cycle",
+			files: []string{"2024_01_01_a", "2024_01_02_b", "2024_01_03_c", "2024_01_04_d"},
+			deps: map[string][]string{
+				"2024_01_02_b": {"2024_01_03_c"},
+				"2024_01_03_c": {"2024_01_04_d"},
+				"2024_01_04_d": {"2024_01_02_b"},
+			},
+			err:  ErrDependencyCycle,
+			want: "2024_01_02_b -> 2024_01_03_c -> 2024_01_04_d -> 2024_01_02_b",
+		},
+		{
+			name:  "file waiting on a cycle",
+			files: []string{"2024_01_01_a", "2024_01_02_b", "2024_01_03_c"},
+			deps: map[string][]string{
+				"2024_01_01_a": {"2024_01_02_b"},
+				"2024_01_02_b": {"2024_01_03_c"},
+				"2024_01_03_c": {"2024_01_02_b"},
+			},
+			err:  ErrDependencyCycle,
+			want: "2024_01_02_b -> 2024_01_03_c -> 2024_01_02_b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := orderMigrations(testFiles(tt.deps, tt.files...))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("orderMigrations() error = %v, want %v", err, tt.err)
+			}
+			if !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("orderMigrations() error = %q, want it to end with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderedMigrationFiles(t *testing.T) {
+	dir := t.TempDir()
+	migrations := map[string]string{
+		"2024_01_01_000000_create_posts_table":  "-- @depends: 2024_01_02_000000_create_users_table\n--UP--\nCREATE TABLE posts (id INT);\n--DOWN--\nDROP TABLE posts;\n",
+		"2024_01_02_000000_create_users_table":  "--UP--\nCREATE TABLE users (id INT);\n--DOWN--\nDROP TABLE users;\n",
+		"2024_01_03_000000_create_tags_table":   "--UP--\n-- @depends: 2024_01_04_000000_create_things_table\nCREATE TABLE tags (id INT);\n",
+		"2024_01_04_000000_create_things_table": "--UP--\nCREATE TABLE things (id INT);\n",
+	}
+	for name, content := range migrations {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &Migration{}
+	ordered, err := m.orderedMigrationFiles(dir)
+	if err != nil {
+		t.Fatalf("orderedMigrationFiles() error = %v", err)
+	}
+
+	// @depends after the first section marker is not a directive
+	want := []string{
+		"2024_01_02_000000_create_users_table",
+		"2024_01_01_000000_create_posts_table",
+		"2024_01_03_000000_create_tags_table",
+		"2024_01_04_000000_create_things_table",
+	}
+	if got := fileNames(ordered); !reflect.DeepEqual(got, want) {
+		t.Errorf("orderedMigrationFiles() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,584 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ColumnSpec describes a column given on the command line as
+// name:type[:modifier...], e.g. email:string:unique or age:int:nullable.
+type ColumnSpec struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Unique   bool
+	Index    bool
+	Default  string
+}
+
+// Blueprint is what a migration name like add_email_to_users describes.
+type Blueprint struct {
+	Action  string // create, add, drop, drop_table, rename, rename_table, alter
+	Table   string
+	Columns []ColumnSpec
+	From    string // rename source (column or table)
+	To      string // rename target (column or table)
+}
+
+const placeholderTable = "table_name"
+
+var numericDefault = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// ParseColumns parses a comma separated list of column specs. Supported
+// modifiers are nullable, unique, index and default=<value>. Commas and
+// colons inside parentheses or quotes do not split, so decimal(8,2) and
+// default='a,b' stay whole.
+func ParseColumns(spec string) ([]ColumnSpec, error) {
+	var columns []ColumnSpec
+
+	for _, part := range splitTopLevel(spec, ',') {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		fields := splitTopLevel(part, ':')
+		column := ColumnSpec{Name: strings.TrimSpace(fields[0]), Type: "string"}
+		if column.Name == "" {
+			return nil, fmt.Errorf("invalid column spec %q: missing name", part)
+		}
+		if len(fields) > 1 && fields[1] != "" {
+			column.Type = strings.TrimSpace(fields[1])
+		}
+
+		for _, modifier := range fields[2:] {
+			modifier = strings.TrimSpace(modifier)
+			switch {
+			case modifier == "nullable":
+				column.Nullable = true
+			case modifier == "unique":
+				column.Unique = true
+			case modifier == "index":
+				column.Index = true
+			case strings.HasPrefix(modifier, "default="):
+				column.Default = unquote(strings.TrimPrefix(modifier, "default="))
+			default:
+				return nil, fmt.Errorf("invalid column spec %q: unknown modifier %q", part, modifier)
+			}
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+// splitTopLevel splits s at sep, except inside parentheses and quotes.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, quote, start := 0, byte(0), 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// unquote strips one pair of matching quotes around a default value.
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// ParseMigrationName derives the action, table and columns from Laravel style
+// names: create_users_table, add_email_to_users, drop_email_from_users,
+// rename_name_to_full_name_in_users, rename_users_to_customers,
+// alter_email_in_users and drop_users_table.
+func ParseMigrationName(name string) Blueprint {
+	switch {
+	case strings.HasPrefix(name, "create_"):
+		return Blueprint{Action: "create", Table: trimTable(strings.TrimPrefix(name, "create_"))}
+
+	case strings.HasPrefix(name, "add_"):
+		rest := strings.TrimPrefix(name, "add_")
+		if cols, table, ok := cutLast(rest, "_to_"); ok {
+			return Blueprint{Action: "add", Table: trimTable(table), Columns: inferColumns(cols)}
+		}
+		return Blueprint{Action: "add", Columns: inferColumns(rest)}
+
+	case strings.HasPrefix(name, "drop_"):
+		rest := strings.TrimPrefix(name, "drop_")
+		if cols, table, ok := cutLast(rest, "_from_"); ok {
+			return Blueprint{Action: "drop", Table: trimTable(table), Columns: inferColumns(cols)}
+		}
+		return Blueprint{Action: "drop_table", Table: trimTable(rest)}
+
+	case strings.HasPrefix(name, "rename_"):
+		rest := strings.TrimPrefix(name, "rename_")
+		if names, table, ok := cutLast(rest, "_in_"); ok {
+			if from, to, ok := strings.Cut(names, "_to_"); ok {
+				return Blueprint{Action: "rename", Table: trimTable(table), From: from, To: to}
+			}
+		}
+		if from, to, ok := strings.Cut(rest, "_to_"); ok {
+			return Blueprint{Action: "rename_table", Table: trimTable(from), From: trimTable(from), To: trimTable(to)}
+		}
+		return Blueprint{Action: "rename_table", Table: trimTable(rest), From: trimTable(rest)}
+
+	case strings.HasPrefix(name, "alter_"):
+		rest := strings.TrimPrefix(name, "alter_")
+		if cols, table, ok := cutLast(rest, "_in_"); ok {
+			return Blueprint{Action: "alter", Table: trimTable(table), Columns: inferColumns(cols)}
+		}
+		return Blueprint{Action: "alter", Table: trimTable(rest)}
+	}
+
+	return Blueprint{Action: "create", Table: name}
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i <= 0 || i+len(sep) >= len(s) {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+func trimTable(name string) string {
+	return strings.TrimSuffix(name, "_table")
+}
+
+// inferColumns turns "email_and_phone_columns" into two nullable string
+// columns, so adding them works on tables that already have rows.
+func inferColumns(names string) []ColumnSpec {
+	names = strings.TrimSuffix(strings.TrimSuffix(names, "_columns"), "_column")
+
+	var columns []ColumnSpec
+	for _, name := range strings.Split(names, "_and_") {
+		if name != "" {
+			columns = append(columns, ColumnSpec{Name: name, Type: "string", Nullable: true})
+		}
+	}
+	return columns
+}
+
+// blueprintSQL renders the UP and DOWN sections for a blueprint.
+func (m *Migration) blueprintSQL(bp Blueprint) (string, string) {
+	table := bp.Table
+	if table == "" {
+		table = placeholderTable
+	}
+
+	switch bp.Action {
+	case "add":
+		return m.addColumnsSQL(table, bp.Columns), m.dropColumnsSQL(table, bp.Columns)
+	case "drop":
+		return m.dropColumnsSQL(table, bp.Columns), m.addColumnsSQL(table, bp.Columns)
+	case "drop_table":
+		return m.dropTableSQL(table), m.createTableSQL(table, bp.Columns)
+	case "rename":
+		return m.renameColumnSQL(table, bp.From, bp.To), m.renameColumnSQL(table, bp.To, bp.From)
+	case "rename_table":
+		to := bp.To
+		if to == "" {
+			to = "new_" + bp.From
+		}
+		return m.renameTableSQL(bp.From, to), m.renameTableSQL(to, bp.From)
+	case "alter":
+		return m.alterColumnsSQL(table, bp.Columns), m.restoreColumnsSQL(table, bp.Columns)
+	}
+
+	return m.createTableSQL(table, bp.Columns), m.dropTableSQL(table)
+}
+
+func (m *Migration) createTableSQL(tableName string, columns []ColumnSpec) string {
+	var indexes []string
+	for _, column := range columns {
+		if column.Index {
+			indexes = append(indexes, m.createIndexSQL(tableName, column, false))
+		}
+	}
+
+	var upSQL string
+
+	switch m.Driver {
+	case "postgres":
+		upSQL = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id SERIAL PRIMARY KEY,
+%s    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);`, tableName, m.columnLines(columns, "    "))
+
+	case "sqlite", "sqlite3":
+		upSQL = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+%s    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);`, tableName, m.columnLines(columns, "    "))
+
+	case "sqlserver", "mssql":
+		upSQL = fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='%s' AND xtype='U')
+    CREATE TABLE %s (
+        id INT IDENTITY(1,1) PRIMARY KEY,
+%s        created_at DATETIME DEFAULT GETDATE(),
+        updated_at DATETIME DEFAULT GETDATE()
+    );`, tableName, tableName, m.columnLines(columns, "        "))
+
+	default: // mysql
+		upSQL = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id INTEGER PRIMARY KEY AUTO_INCREMENT,
+%s    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);`, tableName, m.columnLines(columns, "    "))
+	}
+
+	if len(indexes) > 0 {
+		upSQL += "\n\n" + strings.Join(indexes, "\n")
+	}
+
+	return upSQL
+}
+
+func (m *Migration) columnLines(columns []ColumnSpec, indent string) string {
+	var b strings.Builder
+	for _, column := range columns {
+		definition := m.columnDefinition(column)
+		if column.Unique {
+			definition += " UNIQUE"
+		}
+		b.WriteString(indent + definition + ",\n")
+	}
+	return b.String()
+}
+
+func (m *Migration) dropTableSQL(tableName string) string {
+	if m.Driver == "sqlserver" || m.Driver == "mssql" {
+		return fmt.Sprintf("IF EXISTS (SELECT * FROM sysobjects WHERE name='%s' AND xtype='U') DROP TABLE %s;", tableName, tableName)
+	}
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s;", tableName)
+}
+
+// addColumnsSQL adds the columns, then their indexes. Unique constraints are
+// created as separate indexes because SQLite cannot add them with the column.
+func (m *Migration) addColumnsSQL(tableName string, columns []ColumnSpec) string {
+	columns = withDefaultColumn(columns)
+
+	var lines []string
+	for _, column := range columns {
+		keyword := "ADD COLUMN"
+		if m.Driver == "sqlserver" || m.Driver == "mssql" {
+			keyword = "ADD"
+		}
+		lines = append(lines, fmt.Sprintf("ALTER TABLE %s %s %s;", tableName, keyword, m.columnDefinition(column)))
+	}
+	for _, column := range columns {
+		if column.Unique || column.Index {
+			lines = append(lines, m.createIndexSQL(tableName, column, column.Unique))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// dropColumnsSQL is the inverse of addColumnsSQL: indexes first, then the
+// columns in reverse order.
+func (m *Migration) dropColumnsSQL(tableName string, columns []ColumnSpec) string {
+	columns = withDefaultColumn(columns)
+
+	var lines []string
+	for i := len(columns) - 1; i >= 0; i-- {
+		if columns[i].Unique || columns[i].Index {
+			lines = append(lines, m.dropIndexSQL(tableName, columns[i], columns[i].Unique))
+		}
+	}
+	for i := len(columns) - 1; i >= 0; i-- {
+		lines = append(lines, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", tableName, columns[i].Name))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (m *Migration) renameColumnSQL(tableName, from, to string) string {
+	if m.Driver == "sqlserver" || m.Driver == "mssql" {
+		return fmt.Sprintf("EXEC sp_rename '%s.%s', '%s', 'COLUMN';", tableName, from, to)
+	}
+	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", tableName, from, to)
+}
+
+func (m *Migration) renameTableSQL(from, to string) string {
+	switch m.Driver {
+	case "sqlserver", "mssql":
+		return fmt.Sprintf("EXEC sp_rename '%s', '%s';", from, to)
+	case "postgres", "sqlite", "sqlite3":
+		return fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", from, to)
+	default: // mysql
+		return fmt.Sprintf("RENAME TABLE %s TO %s;", from, to)
+	}
+}
+
+func (m *Migration) alterColumnsSQL(tableName string, columns []ColumnSpec) string {
+	if len(columns) == 0 {
+		return fmt.Sprintf("-- ALTER TABLE %s ...", tableName)
+	}
+
+	var lines []string
+	for _, column := range columns {
+		lines = append(lines, m.modifyColumnSQL(tableName, column)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// restoreColumnsSQL cannot know the previous definitions, so it leaves a
+// commented skeleton to fill in.
+func (m *Migration) restoreColumnsSQL(tableName string, columns []ColumnSpec) string {
+	if len(columns) == 0 {
+		return fmt.Sprintf("-- Reverse the changes made to %s", tableName)
+	}
+
+	lines := []string{"-- Restore the previous column definitions"}
+	for _, column := range columns {
+		column.Type = "previous_type"
+		for _, line := range m.modifyColumnSQL(tableName, column) {
+			if !strings.HasPrefix(line, "--") {
+				line = "-- " + strings.TrimSuffix(line, ";")
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m *Migration) modifyColumnSQL(tableName string, column ColumnSpec) []string {
+	switch m.Driver {
+	case "postgres":
+		nullability := "SET NOT NULL"
+		if column.Nullable {
+			nullability = "DROP NOT NULL"
+		}
+		lines := []string{
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", tableName, column.Name, m.columnType(column.Type)),
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s;", tableName, column.Name, nullability),
+		}
+		if column.Default != "" {
+			lines = append(lines, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;", tableName, column.Name, m.defaultValue(column.Default)))
+		}
+		return lines
+
+	case "sqlite", "sqlite3":
+		return []string{
+			fmt.Sprintf("-- SQLite cannot alter %s.%s in place: create a new table, copy the data, drop the old table and rename", tableName, column.Name),
+		}
+
+	case "sqlserver", "mssql":
+		nullability := "NOT NULL"
+		if column.Nullable {
+			nullability = "NULL"
+		}
+		return []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s %s;", tableName, column.Name, m.columnType(column.Type), nullability)}
+
+	default: // mysql
+		return []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s;", tableName, m.columnDefinition(column))}
+	}
+}
+
+func (m *Migration) createIndexSQL(tableName string, column ColumnSpec, unique bool) string {
+	if unique {
+		return fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s);", indexName(tableName, column.Name, true), tableName, column.Name)
+	}
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s);", indexName(tableName, column.Name, false), tableName, column.Name)
+}
+
+func (m *Migration) dropIndexSQL(tableName string, column ColumnSpec, unique bool) string {
+	name := indexName(tableName, column.Name, unique)
+	switch m.Driver {
+	case "postgres", "sqlite", "sqlite3":
+		return fmt.Sprintf("DROP INDEX IF EXISTS %s;", name)
+	default: // mysql, sqlserver
+		return fmt.Sprintf("DROP INDEX %s ON %s;", name, tableName)
+	}
+}
+
+func indexName(tableName, columnName string, unique bool) string {
+	if unique {
+		return fmt.Sprintf("%s_%s_unique", tableName, columnName)
+	}
+	return fmt.Sprintf("%s_%s_index", tableName, columnName)
+}
+
+// withDefaultColumn keeps add/drop skeletons useful when no column is known.
+func withDefaultColumn(columns []ColumnSpec) []ColumnSpec {
+	if len(columns) == 0 {
+		return []ColumnSpec{{Name: "column_name", Type: "string", Nullable: true}}
+	}
+	return columns
+}
+
+func (m *Migration) columnDefinition(column ColumnSpec) string {
+	definition := fmt.Sprintf("%s %s", column.Name, m.columnType(column.Type))
+
+	if column.Nullable {
+		definition += " NULL"
+	} else {
+		definition += " NOT NULL"
+	}
+
+	if column.Default != "" {
+		definition += " DEFAULT " + m.defaultValue(column.Default)
+	}
+
+	return definition
+}
+
+// columnType maps a portable type name to the driver's type. Unknown types,
+// or ones with explicit arguments like string(100), are passed through.
+func (m *Migration) columnType(name string) string {
+	base, args, hasArgs := strings.Cut(strings.ToLower(name), "(")
+	args = strings.TrimSuffix(args, ")")
+
+	switch m.Driver {
+	case "postgres":
+		switch base {
+		case "string":
+			return "VARCHAR(" + argsOr(args, hasArgs, "255") + ")"
+		case "text":
+			return "TEXT"
+		case "int", "integer":
+			return "INTEGER"
+		case "bigint":
+			return "BIGINT"
+		case "bool", "boolean":
+			return "BOOLEAN"
+		case "decimal":
+			return "DECIMAL(" + argsOr(args, hasArgs, "10,2") + ")"
+		case "float", "double":
+			return "DOUBLE PRECISION"
+		case "date":
+			return "DATE"
+		case "datetime", "timestamp":
+			return "TIMESTAMP"
+		case "uuid":
+			return "UUID"
+		case "json":
+			return "JSONB"
+		}
+
+	case "sqlite", "sqlite3":
+		switch base {
+		case "string":
+			return "VARCHAR(" + argsOr(args, hasArgs, "255") + ")"
+		case "text", "json":
+			return "TEXT"
+		case "int", "integer", "bigint", "bool", "boolean":
+			return "INTEGER"
+		case "decimal":
+			return "NUMERIC"
+		case "float", "double":
+			return "REAL"
+		case "date":
+			return "DATE"
+		case "datetime", "timestamp":
+			return "TIMESTAMP"
+		case "uuid":
+			return "CHAR(36)"
+		}
+
+	case "sqlserver", "mssql":
+		switch base {
+		case "string":
+			return "NVARCHAR(" + argsOr(args, hasArgs, "255") + ")"
+		case "text", "json":
+			return "NVARCHAR(MAX)"
+		case "int", "integer":
+			return "INT"
+		case "bigint":
+			return "BIGINT"
+		case "bool", "boolean":
+			return "BIT"
+		case "decimal":
+			return "DECIMAL(" + argsOr(args, hasArgs, "10,2") + ")"
+		case "float", "double":
+			return "FLOAT"
+		case "date":
+			return "DATE"
+		case "datetime", "timestamp":
+			return "DATETIME"
+		case "uuid":
+			return "UNIQUEIDENTIFIER"
+		}
+
+	default: // mysql
+		switch base {
+		case "string":
+			return "VARCHAR(" + argsOr(args, hasArgs, "255") + ")"
+		case "text":
+			return "TEXT"
+		case "int", "integer":
+			return "INT"
+		case "bigint":
+			return "BIGINT"
+		case "bool", "boolean":
+			return "TINYINT(1)"
+		case "decimal":
+			return "DECIMAL(" + argsOr(args, hasArgs, "10,2") + ")"
+		case "float", "double":
+			return "DOUBLE"
+		case "date":
+			return "DATE"
+		case "datetime", "timestamp":
+			return "TIMESTAMP"
+		case "uuid":
+			return "CHAR(36)"
+		case "json":
+			return "JSON"
+		}
+	}
+
+	return strings.ToUpper(name)
+}
+
+func argsOr(args string, hasArgs bool, fallback string) string {
+	if hasArgs && args != "" {
+		return args
+	}
+	return fallback
+}
+
+func (m *Migration) defaultValue(value string) string {
+	upper := strings.ToUpper(value)
+	switch upper {
+	case "TRUE", "FALSE":
+		// SQL Server BIT columns have no boolean literals
+		if m.Driver == "sqlserver" || m.Driver == "mssql" {
+			if upper == "TRUE" {
+				return "1"
+			}
+			return "0"
+		}
+		return upper
+	case "NULL", "CURRENT_TIMESTAMP":
+		return upper
+	}
+	if numericDefault.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
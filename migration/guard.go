@@ -0,0 +1,123 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hymns/go-artisan/statement"
+)
+
+// ErrDestructiveBlocked is returned when PreventDestructive is set and an
+// operation would drop or delete data.
+var ErrDestructiveBlocked = errors.New("destructive operation blocked")
+
+// ErrIrreversible is returned when a rollback reaches a migration marked
+// -- @irreversible and AllowIrreversible is not set.
+var ErrIrreversible = errors.New("migration is irreversible")
+
+var destructivePattern = regexp.MustCompile(`(?is)^\s*(` +
+	`DROP\s+(TABLE|DATABASE|SCHEMA|VIEW|MATERIALIZED\s+VIEW|SEQUENCE|TYPE)\b` +
+	`|TRUNCATE\b` +
+	`|ALTER\s+TABLE\s+.*\bDROP\b` +
+	`|DELETE\s+FROM\s+\S+\s*$` +
+	`)`)
+
+// isDestructive reports whether stmt drops objects or removes data. It is a
+// conservative check used by the PreventDestructive guard.
+func isDestructive(stmt string) bool {
+	return destructivePattern.MatchString(stmt)
+}
+
+func (m *Migration) guardDestructive(operation string) error {
+	if m.PreventDestructive {
+		return fmt.Errorf("%w: %s is not allowed", ErrDestructiveBlocked, operation)
+	}
+	return nil
+}
+
+func (m *Migration) guardStatements(name string, statements []statement.Statement) error {
+	if !m.PreventDestructive {
+		return nil
+	}
+	for _, stmt := range statements {
+		if isDestructive(stmt.SQL) {
+			return fmt.Errorf("%w: migration %s statement %d (line %d): %s", ErrDestructiveBlocked, name, stmt.Index, stmt.StartLine, truncateSQL(stmt.SQL, 80))
+		}
+	}
+	return nil
+}
+
+// irreversible reports whether a migration is marked -- @irreversible, either
+// in its header or in the DOWN section for the active driver, and the reason
+// given after the colon, if any.
+func (m *Migration) irreversible(text string) (bool, string) {
+	if header := parseDirectives(text); header.irreversible {
+		return true, header.irreversibleReason
+	}
+
+	down, err := m.sectionSQL(text, false)
+	if err != nil {
+		return false, ""
+	}
+	for _, line := range strings.Split(down, "\n") {
+		match := directivePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match != nil && strings.EqualFold(match[1], "irreversible") {
+			return true, strings.TrimSpace(match[2])
+		}
+	}
+	return false, ""
+}
+
+// guardIrreversible refuses to roll back an irreversible migration unless
+// AllowIrreversible is set.
+func (m *Migration) guardIrreversible(name, text string) error {
+	ok, reason := m.irreversible(text)
+	if !ok || m.AllowIrreversible {
+		return nil
+	}
+	if reason != "" {
+		return fmt.Errorf("%w: %s cannot be rolled back (%s); use --allow-irreversible to remove its record anyway", ErrIrreversible, name, reason)
+	}
+	return fmt.Errorf("%w: %s cannot be rolled back; use --allow-irreversible to remove its record anyway", ErrIrreversible, name)
+}
+
+// IrreversibleApplied lists applied migrations that are marked
+// -- @irreversible, newest first. Rebuilding the database, e.g. with
+// migrate:fresh, would lose what they did.
+func (m *Migration) IrreversibleApplied(migrationsPath string) ([]string, error) {
+	applied, err := m.getApplied()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migrated list: %w", err)
+	}
+
+	var names []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		content, err := os.ReadFile(filepath.Join(migrationsPath, applied[i].Name))
+		if err != nil {
+			continue
+		}
+		if ok, _ := m.irreversible(string(content)); ok {
+			names = append(names, applied[i].Name)
+		}
+	}
+	return names, nil
+}
+
+// guardBatch runs guardIrreversible over every migration in names whose file
+// can still be read.
+func (m *Migration) guardBatch(migrationsPath string, names []string) error {
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(migrationsPath, name))
+		if err != nil {
+			continue
+		}
+		if err := m.guardIrreversible(name, string(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hymns/go-artisan/expand"
+)
+
+type LintIssue struct {
+	File    string
+	Message string
+}
+
+// Lint checks every migration file against the active driver without
+// touching the database.
+func (m *Migration) Lint(migrationsPath string) ([]LintIssue, error) {
+	files, err := m.getMigrationFiles(migrationsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration files: %w", err)
+	}
+
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = filepath.Base(file)
+	}
+
+	var issues []LintIssue
+	if _, err := m.orderedMigrationFiles(migrationsPath); errors.Is(err, ErrDependencyCycle) {
+		issues = append(issues, LintIssue{File: filepath.Base(migrationsPath), Message: err.Error()})
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			issues = append(issues, LintIssue{File: name, Message: err.Error()})
+			continue
+		}
+
+		header := parseDirectives(string(content))
+		for _, directive := range header.unknown {
+			issues = append(issues, LintIssue{File: name, Message: "unknown directive " + directive})
+		}
+		for _, problem := range header.invalid {
+			issues = append(issues, LintIssue{File: name, Message: problem})
+		}
+		for _, dep := range header.depends {
+			if !contains(names, dep) {
+				issues = append(issues, LintIssue{File: name, Message: "depends on unknown migration " + dep})
+			}
+		}
+
+		for _, warning := range m.sectionWarnings(string(content)) {
+			issues = append(issues, LintIssue{File: name, Message: warning})
+		}
+
+		if irreversible, _ := m.irreversible(string(content)); !irreversible {
+			if statements, err := m.parseMigrationContent(string(content), false); err == nil && len(statements) == 0 {
+				issues = append(issues, LintIssue{File: name, Message: "empty DOWN section; mark it -- @irreversible if it cannot be undone"})
+			}
+		}
+
+		for _, isUp := range []bool{true, false} {
+			section, err := m.sectionSQL(string(content), isUp)
+			if err != nil {
+				continue // already reported above
+			}
+			if _, err := expand.SQL(section, m.Driver, m.Variables); err != nil {
+				direction := "DOWN"
+				if isUp {
+					direction = "UP"
+				}
+				issues = append(issues, LintIssue{File: name, Message: fmt.Sprintf("%s section: %v", direction, err)})
+			}
+		}
+	}
+
+	return issues, nil
+}
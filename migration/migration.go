@@ -1,7 +1,9 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,11 +12,66 @@ import (
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/hymns/go-artisan/expand"
+	"github.com/hymns/go-artisan/history"
+	"github.com/hymns/go-artisan/retry"
+	"github.com/hymns/go-artisan/statement"
+	"github.com/hymns/go-artisan/stub"
+	"github.com/hymns/go-artisan/telemetry"
 )
 
 type Migration struct {
 	DB     *sql.DB
 	Driver string
+
+	// Instrumentation receives spans and metrics. Nil disables it.
+	Instrumentation telemetry.Instrumentation
+
+	// PreventDestructive refuses rollbacks and any migration whose UP
+	// section drops or truncates, returning ErrDestructiveBlocked.
+	PreventDestructive bool
+
+	// StubsPath is checked for a migration.stub before the built-in template.
+	StubsPath string
+
+	// Variables resolve ${NAME} references before the environment does.
+	Variables map[string]string
+
+	// Tags limits Migrate, AutoMigrate, DryRun and Status to migrations
+	// with one of these -- @tags. Empty means all migrations.
+	Tags []string
+	// AllowIrreversible lets rollbacks pass migrations marked
+	// -- @irreversible. Their DOWN section still runs, which usually only
+	// removes the record, so the schema is left as it is.
+	AllowIrreversible bool
+
+	// Phase limits Migrate, DryRun and Status to PhaseExpand or
+	// PhaseContract migrations. Empty means both. AutoMigrate always runs
+	// expand migrations only.
+	Phase string
+
+	// Retry re-runs a migration's transaction when it fails with a
+	// transient error such as a deadlock or a dropped connection.
+	// Migrations marked -- @no-transaction are never retried, and neither
+	// are MySQL migrations once a DDL statement has committed implicitly.
+	Retry retry.Policy
+
+	// WaitTimeout makes AutoMigrate wait up to this long for the database
+	// to accept connections, see WaitForDB. Zero connects once.
+	WaitTimeout time.Duration
+
+	// InitStatements run at the start of every migration and rollback,
+	// before the file's -- @set directives, e.g. "SET ROLE migrator".
+	InitStatements []string
+
+	// Timeout limits each migration and rollback; -- @timeout overrides it.
+	// The transaction is rolled back and ErrTimeout returned when it runs
+	// out. Zero means no limit.
+	Timeout time.Duration
+
+	// only limits pending migrations to these names when not nil, so the
+	// shards after a canary apply the same batch it did
+	only []string
 }
 
 func New(db *sql.DB) *Migration {
@@ -79,6 +136,28 @@ func (m *Migration) EnsureMigrationsTable() error {
 	return m.ensureLockTable()
 }
 
+// tableExists reports whether the named table exists, without creating it.
+func (m *Migration) tableExists(name string) (bool, error) {
+	var query string
+
+	switch m.Driver {
+	case "postgres":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	case "sqlite", "sqlite3":
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	case "sqlserver", "mssql":
+		query = "SELECT COUNT(*) FROM sysobjects WHERE name = @p1 AND xtype = 'U'"
+	default: // mysql
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	}
+
+	var count int
+	if err := m.DB.QueryRow(query, name).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func (m *Migration) ensureLockTable() error {
 	var query string
 
@@ -133,6 +212,11 @@ func (m *Migration) ensureLockTable() error {
 }
 
 func (m *Migration) acquireLock() error {
+	started := time.Now()
+	defer func() {
+		m.instrumentation().ObserveDuration(telemetry.LockWait, time.Since(started), nil)
+	}()
+
 	// Try to acquire lock
 	var locked int
 	err := m.DB.QueryRow("SELECT locked FROM migration_lock WHERE id = 1").Scan(&locked)
@@ -154,6 +238,55 @@ func (m *Migration) acquireLock() error {
 	return nil
 }
 
+type LockStatus struct {
+	Locked   bool
+	LockedAt string
+	LockedBy string
+}
+
+// LockStatus reports whether a migration run currently holds the lock.
+// It only reads, so a database that was never migrated reports no lock.
+func (m *Migration) LockStatus() (LockStatus, error) {
+	exists, err := m.tableExists("migration_lock")
+	if err != nil {
+		return LockStatus{}, fmt.Errorf("failed to check lock table: %w", err)
+	}
+	if !exists {
+		return LockStatus{}, nil
+	}
+
+	var locked interface{}
+	var lockedAt, lockedBy sql.NullString
+	err = m.DB.QueryRow("SELECT locked, locked_at, locked_by FROM migration_lock WHERE id = 1").Scan(&locked, &lockedAt, &lockedBy)
+	if err == sql.ErrNoRows {
+		return LockStatus{}, nil
+	}
+	if err != nil {
+		return LockStatus{}, fmt.Errorf("failed to check lock status: %w", err)
+	}
+
+	return LockStatus{
+		Locked:   isTruthy(locked),
+		LockedAt: lockedAt.String,
+		LockedBy: lockedBy.String,
+	}, nil
+}
+
+func isTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case []byte:
+		s := string(v)
+		return s == "1" || strings.EqualFold(s, "true")
+	case string:
+		return v == "1" || strings.EqualFold(v, "true")
+	}
+	return false
+}
+
 func (m *Migration) releaseLock() error {
 	_, err := m.DB.Exec("UPDATE migration_lock SET locked = 0, locked_at = NULL, locked_by = NULL WHERE id = 1")
 	if err != nil {
@@ -162,7 +295,10 @@ func (m *Migration) releaseLock() error {
 	return nil
 }
 
-func (m *Migration) MigrateFile(filePath string) error {
+func (m *Migration) MigrateFile(filePath string) (err error) {
+	ctx, finish := m.startCommand("migrate_file")
+	defer func() { finish(err) }()
+
 	if err := m.EnsureMigrationsTable(); err != nil {
 		return fmt.Errorf("failed to ensure migrations table: %w", err)
 	}
@@ -191,46 +327,360 @@ func (m *Migration) MigrateFile(filePath string) error {
 		return nil
 	}
 
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read migration %s: %w", name, err)
+	}
+	for _, dep := range parseDirectives(string(content)).depends {
+		if !contains(migrated, dep) {
+			return fmt.Errorf("%w: %s depends on %s", ErrDependencyPending, name, dep)
+		}
+	}
+
+	if err := m.runUp(ctx, filePath, batch); err != nil {
+		return err
+	}
+
+	color.Green("✓ Migrated: %s", name)
+	return nil
+}
+
+// runUp applies a single migration file and records it, all within one
+// transaction unless the file is marked -- @no-transaction.
+func (m *Migration) runUp(ctx context.Context, filePath string, batch int) (err error) {
+	name := filepath.Base(filePath)
+	inst := m.instrumentation()
+	labels := map[string]string{"direction": "up"}
+	started := time.Now()
+
+	ctx, span := inst.StartSpan(ctx, "artisan.migration")
+	span.SetAttribute("migration.name", name)
+	span.SetAttribute("migration.direction", "up")
+	span.SetAttribute("migration.batch", batch)
+	var content []byte
+	defer func() {
+		inst.ObserveDuration(telemetry.MigrationDuration, time.Since(started), labels)
+		if err != nil {
+			inst.IncCounter(telemetry.MigrationsFailed, labels)
+		} else {
+			inst.IncCounter(telemetry.MigrationsApplied, labels)
+		}
+		m.recordHistory(name, history.DirectionUp, batch, content, started, err)
+		span.End(err)
+	}()
+
 	// Read and parse SQL file
-	statements, err := m.parseMigrationSQL(filePath, true) // true = UP
+	content, err = os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to parse migration %s: %w", name, err)
 	}
 
-	// Start transaction for atomic migration
-	tx, err := m.DB.Begin()
+	statements, err := m.parseMigrationContent(string(content), true) // true = UP
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction for migration %s: %w", name, err)
+		return fmt.Errorf("failed to parse migration %s: %w", name, err)
 	}
 
-	// Execute each SQL statement within transaction
-	for _, stmt := range statements {
-		if stmt == "" {
-			continue
-		}
-		if _, err := tx.Exec(stmt); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to run migration %s: %w", name, err)
+	if err := m.guardStatements(name, statements); err != nil {
+		return err
+	}
+
+	d := parseDirectives(string(content))
+	ran := 0
+	if d.noTransaction {
+		return m.session(ctx, name, d, false, func(ctx context.Context, db execer) error {
+			return m.applyUp(ctx, db, filePath, statements, batch, &ran)
+		})
+	}
+
+	// A retry starts the transaction over, which is only safe when none of
+	// it has been committed already
+	retryable := func(err error) bool {
+		var stmtErr *statement.Error
+		failed := errors.As(err, &stmtErr)
+		return !errors.Is(err, ErrTimeout) && statement.IsTransient(m.Driver, err) &&
+			!statement.CommittedImplicitly(canonicalDriver(m.Driver), statements[:ran], failed)
+	}
+
+	attempts := 0
+	defer func() { span.SetAttribute("migration.attempts", attempts) }()
+
+	return m.Retry.Do(ctx, func(attempt int) error {
+		attempts = attempt
+		ran = 0
+		return m.session(ctx, name, d, true, func(ctx context.Context, db execer) error {
+			return m.applyUp(ctx, db, filePath, statements, batch, &ran)
+		})
+	}, retryable, func(attempt int, err error, delay time.Duration) {
+		color.Yellow("⚠ Migration %s failed on attempt %d/%d, retrying in %s: %v", name, attempt, m.Retry.MaxAttempts, delay, err)
+	})
+}
+
+// applyUp runs the statements and records the migration. ran counts the
+// statements that were started.
+func (m *Migration) applyUp(ctx context.Context, db execer, filePath string, statements []statement.Statement, batch int, ran *int) error {
+	name := filepath.Base(filePath)
+
+	for i, stmt := range statements {
+		*ran = i + 1
+		if err := m.execStatement(ctx, db, stmt); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", name, statement.NewError(filePath, m.Driver, stmt, err))
 		}
 	}
 
-	// Record migration within same transaction
 	query := fmt.Sprintf("INSERT INTO migrations (migration, batch) VALUES (%s, %s)", m.placeholder(1), m.placeholder(2))
-	if _, err := tx.Exec(query, name, batch); err != nil {
-		tx.Rollback()
+	if _, err := db.ExecContext(ctx, query, name, batch); err != nil {
 		return fmt.Errorf("failed to record migration %s: %w", name, err)
 	}
 
-	// Commit transaction
+	return nil
+}
+
+// runDown executes the DOWN section of a migration file and removes its record.
+func (m *Migration) runDown(ctx context.Context, filePath string, batch int) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse migration %s: %w", filepath.Base(filePath), err)
+	}
+
+	return m.runDownContent(ctx, filePath, string(content), batch)
+}
+
+// runDownContent is runDown for migration text that may no longer match the
+// file on disk, e.g. the version that was applied before an edit.
+func (m *Migration) runDownContent(ctx context.Context, filePath, content string, batch int) (err error) {
+	name := filepath.Base(filePath)
+	if err := m.guardDestructive("rollback"); err != nil {
+		return err
+	}
+
+	inst := m.instrumentation()
+	labels := map[string]string{"direction": "down"}
+	started := time.Now()
+
+	ctx, span := inst.StartSpan(ctx, "artisan.migration")
+	span.SetAttribute("migration.name", name)
+	span.SetAttribute("migration.direction", "down")
+	defer func() {
+		inst.ObserveDuration(telemetry.MigrationDuration, time.Since(started), labels)
+		if err != nil {
+			inst.IncCounter(telemetry.MigrationsFailed, labels)
+		} else {
+			inst.IncCounter(telemetry.MigrationsApplied, labels)
+		}
+		m.recordHistory(name, history.DirectionDown, batch, []byte(content), started, err)
+		span.End(err)
+	}()
+
+	if err := m.guardIrreversible(name, content); err != nil {
+		return err
+	}
+
+	statements, err := m.parseMigrationContent(content, false) // false = DOWN
+	if err != nil {
+		return fmt.Errorf("failed to parse migration %s: %w", name, err)
+	}
+	if irreversible, _ := m.irreversible(content); irreversible {
+		color.Yellow("⚠ Forcing rollback of irreversible migration %s; its changes are not undone", name)
+	} else if len(statements) == 0 {
+		color.Yellow("⚠ Migration %s has an empty DOWN section; only its record is removed", name)
+	}
+
+	d := parseDirectives(content)
+	return m.session(ctx, name, d, !d.noTransaction, func(ctx context.Context, db execer) error {
+		for _, stmt := range statements {
+			if err := m.execStatement(ctx, db, stmt); err != nil {
+				return fmt.Errorf("failed to rollback migration %s: %w", name, statement.NewError(filePath, m.Driver, stmt, err))
+			}
+		}
+
+		query := fmt.Sprintf("DELETE FROM migrations WHERE migration = %s", m.placeholder(1))
+		if _, err := db.ExecContext(ctx, query, name); err != nil {
+			return fmt.Errorf("failed to delete migration record %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// session runs fn on a single connection, in a transaction unless
+// inTransaction is false. The timeout, InitStatements and the file's
+// -- @set directives are applied first, so they cover every statement.
+// Server-side timeouts that outlive the transaction are reset before the
+// connection goes back to the pool.
+func (m *Migration) session(ctx context.Context, name string, d directives, inTransaction bool, fn func(ctx context.Context, db execer) error) (err error) {
+	timeout := d.timeout
+	if timeout == 0 {
+		timeout = m.Timeout
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		defer func() { err = timeoutError(ctx, timeout, err) }()
+	}
+
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect for migration %s: %w", name, err)
+	}
+	defer conn.Close()
+
+	queries, reset := m.sessionQueries(d, timeout, inTransaction)
+	defer func() {
+		for _, query := range reset {
+			conn.ExecContext(context.Background(), query)
+		}
+	}()
+	if timeout > 0 {
+		defer statement.KillOnDeadline(ctx, canonicalDriver(m.Driver), m.DB, conn)()
+	}
+
+	if !inTransaction {
+		if err := m.initSession(ctx, conn, name, queries); err != nil {
+			return err
+		}
+		return fn(ctx, conn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for migration %s: %w", name, err)
+	}
+
+	if err := m.initSession(ctx, tx, name, queries); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := fn(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit migration %s: %w", name, err)
 	}
+	return nil
+}
 
-	color.Green("✓ Migrated: %s", name)
+// sessionQueries returns the timeouts, InitStatements and -- @set statements
+// in that order, so the migration's own settings win, along with what
+// restores the connection afterwards. On PostgreSQL, @set inside a
+// transaction uses SET LOCAL and ends with it.
+func (m *Migration) sessionQueries(d directives, timeout time.Duration, inTransaction bool) (queries, reset []string) {
+	driver := canonicalDriver(m.Driver)
+	if timeout > 0 {
+		queries, reset = statement.Timeouts(driver, timeout, inTransaction)
+	}
+
+	// Only session-wide settings need undoing
+	settings := append([]string{}, m.InitStatements...)
+	queries = append(queries, m.InitStatements...)
+	for _, setting := range d.set {
+		if driver == "postgres" && inTransaction {
+			queries = append(queries, "SET LOCAL "+setting)
+			continue
+		}
+		queries = append(queries, "SET "+setting)
+		settings = append(settings, "SET "+setting)
+	}
+
+	return queries, append(reset, statement.Resets(driver, settings)...)
+}
+
+func (m *Migration) initSession(ctx context.Context, db execer, name string, queries []string) error {
+	for _, query := range queries {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to initialize session for migration %s: %s: %w", name, query, err)
+		}
+	}
 	return nil
 }
 
-func (m *Migration) Migrate(migrationsPath string) error {
+// timeoutError marks err as ErrTimeout when the deadline passed or the
+// server cancelled a statement for running too long.
+func timeoutError(ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var statementTimeout *statement.StatementTimeoutError
+	var lockTimeout *statement.LockTimeoutError
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.As(err, &statementTimeout) || errors.As(err, &lockTimeout) {
+		return fmt.Errorf("%w after %s: %w", ErrTimeout, timeout, err)
+	}
+	return err
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (m *Migration) execStatement(ctx context.Context, db execer, stmt statement.Statement) error {
+	_, span := m.instrumentation().StartSpan(ctx, "artisan.statement")
+	span.SetAttribute("db.statement", truncateSQL(stmt.SQL, 200))
+	span.SetAttribute("statement.index", stmt.Index)
+	span.SetAttribute("statement.line", stmt.StartLine)
+
+	_, err := db.ExecContext(ctx, stmt.SQL)
+	span.End(err)
+	return err
+}
+
+// recordHistory appends to the audit log. It runs outside the migration
+// transaction so failed attempts are kept as well.
+func (m *Migration) recordHistory(name, direction string, batch int, content []byte, started time.Time, err error) {
+	entry := history.Entry{
+		Migration: name,
+		Direction: direction,
+		Batch:     batch,
+		Duration:  time.Since(started),
+		Outcome:   history.OutcomeSuccess,
+	}
+	if content != nil {
+		entry.Checksum = history.Checksum(content)
+	}
+	if err != nil {
+		entry.Outcome = history.OutcomeFailure
+		entry.Error = err.Error()
+	}
+
+	if err := history.Record(m.DB, m.Driver, entry); err != nil {
+		color.Yellow("⚠ Failed to write migration history for %s: %v", name, err)
+	}
+}
+
+// History returns the audit log of every migration and seeder run.
+func (m *Migration) History(filter history.Filter) ([]history.Entry, error) {
+	return history.List(m.DB, m.Driver, filter)
+}
+
+func (m *Migration) instrumentation() telemetry.Instrumentation {
+	return telemetry.Or(m.Instrumentation)
+}
+
+// startCommand opens the top-level span for a public entry point and records
+// its duration once the returned function is called with the final error.
+func (m *Migration) startCommand(command string) (context.Context, func(error)) {
+	inst := m.instrumentation()
+	started := time.Now()
+
+	ctx, span := inst.StartSpan(context.Background(), "artisan."+command)
+	span.SetAttribute("db.system", m.Driver)
+
+	return ctx, func(err error) {
+		status := "success"
+		if err != nil {
+			status = "failure"
+		}
+		inst.ObserveDuration(telemetry.CommandDuration, time.Since(started), map[string]string{"command": command, "status": status})
+		span.End(err)
+	}
+}
+
+func (m *Migration) Migrate(migrationsPath string) (err error) {
+	ctx, finish := m.startCommand("migrate")
+	defer func() { finish(err) }()
+
 	if err := m.EnsureMigrationsTable(); err != nil {
 		return fmt.Errorf("failed to ensure migrations table: %w", err)
 	}
@@ -251,55 +701,18 @@ func (m *Migration) Migrate(migrationsPath string) error {
 		return fmt.Errorf("failed to get next batch: %w", err)
 	}
 
-	files, err := m.getMigrationFiles(migrationsPath)
+	files, err := m.pendingMigrations(migrationsPath, migrated, m.Phase)
 	if err != nil {
-		return fmt.Errorf("failed to get migration files: %w", err)
+		return fmt.Errorf("failed to resolve migrations: %w", err)
 	}
 
 	executed := 0
 	for _, file := range files {
-		name := filepath.Base(file)
-
-		if contains(migrated, name) {
-			continue
-		}
-
-		// Read and parse SQL file
-		statements, err := m.parseMigrationSQL(file, true) // true = UP
-		if err != nil {
-			return fmt.Errorf("failed to parse migration %s: %w", name, err)
+		if err := m.runUp(ctx, file.path, batch); err != nil {
+			return err
 		}
 
-		// Start transaction for atomic migration
-		tx, err := m.DB.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction for migration %s: %w", name, err)
-		}
-
-		// Execute each SQL statement within transaction
-		for _, stmt := range statements {
-			if stmt == "" {
-				continue
-			}
-			if _, err := tx.Exec(stmt); err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to run migration %s: %w", name, err)
-			}
-		}
-
-		// Record migration within same transaction
-		query := fmt.Sprintf("INSERT INTO migrations (migration, batch) VALUES (%s, %s)", m.placeholder(1), m.placeholder(2))
-		if _, err := tx.Exec(query, name, batch); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record migration %s: %w", name, err)
-		}
-
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %s: %w", name, err)
-		}
-
-		color.Green("✓ Migrated: %s", name)
+		color.Green("✓ Migrated: %s", file.name)
 		executed++
 	}
 
@@ -311,23 +724,65 @@ func (m *Migration) Migrate(migrationsPath string) error {
 }
 
 func (m *Migration) Rollback(migrationsPath string) error {
-	batch, err := m.getLastBatch()
+	return m.RollbackSteps(migrationsPath, 1)
+}
+
+// RollbackSteps reverts the last steps batches, newest first. Every batch is
+// checked for irreversible migrations before any of them is rolled back.
+func (m *Migration) RollbackSteps(migrationsPath string, steps int) (err error) {
+	ctx, finish := m.startCommand("rollback")
+	defer func() { finish(err) }()
+
+	if err := m.guardDestructive("rollback"); err != nil {
+		return err
+	}
+
+	if err := m.EnsureMigrationsTable(); err != nil {
+		return fmt.Errorf("failed to ensure migrations table: %w", err)
+	}
+
+	// Acquire lock to prevent concurrent migrations
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+	defer m.releaseLock()
+
+	batches, err := m.getLastBatches(steps)
 	if err != nil {
-		return fmt.Errorf("failed to get last batch: %w", err)
+		return fmt.Errorf("failed to get last batches: %w", err)
 	}
 
-	if batch == 0 {
+	names := make(map[int][]string)
+	for _, batch := range batches {
+		if names[batch], err = m.getBatchMigrations(batch); err != nil {
+			return fmt.Errorf("failed to get batch migrations: %w", err)
+		}
+	}
+
+	if len(batches) == 0 {
 		color.Cyan("Nothing to rollback.")
 		return nil
 	}
 
-	files, err := m.getBatchMigrations(batch)
-	if err != nil {
-		return fmt.Errorf("failed to get batch migrations: %w", err)
+	// Stop before touching anything when an irreversible migration is in the way
+	for _, batch := range batches {
+		if err := m.guardBatch(migrationsPath, names[batch]); err != nil {
+			return err
+		}
 	}
 
-	for i := len(files) - 1; i >= 0; i-- {
-		name := files[i]
+	for _, batch := range batches {
+		if err := m.rollbackMigrations(ctx, migrationsPath, names[batch], batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackMigrations reverts the given migrations in order. Callers pass them
+// newest first, after checking them with guardBatch.
+func (m *Migration) rollbackMigrations(ctx context.Context, migrationsPath string, names []string, batch int) error {
+	for _, name := range names {
 		filePath := filepath.Join(migrationsPath, name)
 
 		// Check if file exists
@@ -337,27 +792,14 @@ func (m *Migration) Rollback(migrationsPath string) error {
 			if err := m.deleteMigration(name); err != nil {
 				return fmt.Errorf("failed to delete migration record %s: %w", name, err)
 			}
-			continue
-		}
-
-		// Read and parse SQL file
-		statements, err := m.parseMigrationSQL(filePath, false) // false = DOWN
-		if err != nil {
-			return fmt.Errorf("failed to parse migration %s: %w", name, err)
-		}
-
-		// Execute each SQL statement
-		for _, stmt := range statements {
-			if stmt == "" {
-				continue
-			}
-			if _, err := m.DB.Exec(stmt); err != nil {
-				return fmt.Errorf("failed to rollback migration %s: %w", name, err)
+			if err := history.Record(m.DB, m.Driver, history.Entry{Migration: name, Direction: history.DirectionDown, Batch: batch, Outcome: history.OutcomeRemoved, Error: "migration file not found"}); err != nil {
+				color.Yellow("⚠ Failed to write migration history for %s: %v", name, err)
 			}
+			continue
 		}
 
-		if err := m.deleteMigration(name); err != nil {
-			return fmt.Errorf("failed to delete migration record %s: %w", name, err)
+		if err := m.runDown(ctx, filePath, batch); err != nil {
+			return err
 		}
 
 		color.Green("✓ Rolled back: %s", name)
@@ -419,6 +861,47 @@ func (m *Migration) getLastBatch() (int, error) {
 	return int(batch.Int64), nil
 }
 
+// getBatches maps each applied migration to its batch.
+func (m *Migration) getBatches() (map[string]int, error) {
+	rows, err := m.DB.Query("SELECT migration, batch FROM migrations ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var batch int
+		if err := rows.Scan(&name, &batch); err != nil {
+			return nil, err
+		}
+		batches[name] = batch
+	}
+
+	return batches, rows.Err()
+}
+
+// getLastBatches returns up to n of the highest batch numbers, newest first.
+func (m *Migration) getLastBatches(n int) ([]int, error) {
+	rows, err := m.DB.Query("SELECT DISTINCT batch FROM migrations ORDER BY batch DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []int
+	for len(batches) < n && rows.Next() {
+		var batch int
+		if err := rows.Scan(&batch); err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+
+	return batches, rows.Err()
+}
+
 func (m *Migration) GetLastBatch() (int, error) {
 	return m.getLastBatch()
 }
@@ -475,11 +958,21 @@ func (m *Migration) getMigrationFiles(path string) ([]string, error) {
 }
 
 func (m *Migration) MakeMigration(tableName, migrationName, migrationsPath string) error {
+	return m.MakeMigrationWithColumns(tableName, migrationName, migrationsPath, nil)
+}
+
+// MakeMigrationWithColumns creates a migration whose SQL matches the name's
+// prefix (create_, add_, drop_, rename_, alter_). Columns, when given, replace
+// the ones inferred from the name.
+func (m *Migration) MakeMigrationWithColumns(tableName, migrationName, migrationsPath string, columns []ColumnSpec) error {
 	timestamp := time.Now().Format("2006_01_02_150405")
 	filename := fmt.Sprintf("%s_%s", timestamp, migrationName)
 	filepath := filepath.Join(migrationsPath, filename)
 
-	template := m.getMigrationTemplate(tableName, migrationName)
+	template, err := m.getMigrationTemplate(tableName, migrationName, columns)
+	if err != nil {
+		return err
+	}
 
 	if err := os.MkdirAll(migrationsPath, 0755); err != nil {
 		return fmt.Errorf("failed to create migrations directory: %w", err)
@@ -493,54 +986,23 @@ func (m *Migration) MakeMigration(tableName, migrationName, migrationsPath strin
 	return nil
 }
 
-func (m *Migration) getMigrationTemplate(tableName, migrationName string) string {
-	var upSQL, downSQL string
-
-	switch m.Driver {
-	case "postgres":
-		upSQL = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
-    id SERIAL PRIMARY KEY,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);`, tableName)
-		downSQL = fmt.Sprintf("DROP TABLE IF EXISTS %s;", tableName)
-
-	case "sqlite", "sqlite3":
-		upSQL = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);`, tableName)
-		downSQL = fmt.Sprintf("DROP TABLE IF EXISTS %s;", tableName)
-
-	case "sqlserver", "mssql":
-		upSQL = fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='%s' AND xtype='U')
-    CREATE TABLE %s (
-        id INT IDENTITY(1,1) PRIMARY KEY,
-        created_at DATETIME DEFAULT GETDATE(),
-        updated_at DATETIME DEFAULT GETDATE()
-    );`, tableName, tableName)
-		downSQL = fmt.Sprintf("IF EXISTS (SELECT * FROM sysobjects WHERE name='%s' AND xtype='U') DROP TABLE %s;", tableName, tableName)
-
-	default: // mysql
-		upSQL = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
-    id INTEGER PRIMARY KEY AUTO_INCREMENT,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
-);`, tableName)
-		downSQL = fmt.Sprintf("DROP TABLE IF EXISTS %s;", tableName)
+func (m *Migration) getMigrationTemplate(tableName, migrationName string, columns []ColumnSpec) (string, error) {
+	bp := ParseMigrationName(migrationName)
+	if tableName != "" {
+		bp.Table = tableName
+		if bp.Action == "rename_table" {
+			bp.From = tableName
+		}
+	}
+	if len(columns) > 0 {
+		bp.Columns = columns
 	}
 
-	return fmt.Sprintf(`-- Migration: %s
--- Created at: %s
--- Database: %s
-
---UP--
-%s
+	data := stub.NewData(migrationName, bp.Table, m.Driver)
+	data.Action = bp.Action
+	data.Up, data.Down = m.blueprintSQL(bp)
 
---DOWN--
-%s
-`, migrationName, time.Now().Format("2006-01-02 15:04:05"), m.Driver, upSQL, downSQL)
+	return stub.Render(m.StubsPath, stub.Migration, data)
 }
 
 func contains(slice []string, item string) bool {
@@ -563,7 +1025,16 @@ func (m *Migration) placeholder(position int) string {
 	}
 }
 
-func (m *Migration) AutoMigrate(migrationsPath string) error {
+func (m *Migration) AutoMigrate(migrationsPath string) (err error) {
+	ctx, finish := m.startCommand("auto_migrate")
+	defer func() { finish(err) }()
+
+	if m.WaitTimeout > 0 {
+		if err := WaitForDB(m.DB, m.WaitTimeout); err != nil {
+			return err
+		}
+	}
+
 	if err := m.EnsureMigrationsTable(); err != nil {
 		return fmt.Errorf("failed to ensure migrations table: %w", err)
 	}
@@ -584,55 +1055,86 @@ func (m *Migration) AutoMigrate(migrationsPath string) error {
 		return fmt.Errorf("failed to get next batch: %w", err)
 	}
 
-	files, err := m.getMigrationFiles(migrationsPath)
+	files, err := m.pendingMigrations(migrationsPath, migrated, PhaseExpand)
 	if err != nil {
-		return fmt.Errorf("failed to get migration files: %w", err)
+		return fmt.Errorf("failed to resolve migrations: %w", err)
 	}
 
-	executed := 0
 	for _, file := range files {
-		name := filepath.Base(file)
-
-		if contains(migrated, name) {
-			continue
+		if err := m.runUp(ctx, file.path, batch); err != nil {
+			return err
 		}
+	}
 
-		statements, err := m.parseMigrationSQL(file, true)
-		if err != nil {
-			return fmt.Errorf("failed to parse migration %s: %w", name, err)
-		}
+	return nil
+}
 
-		// Start transaction for atomic migration
-		tx, err := m.DB.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction for migration %s: %w", name, err)
-		}
+// LastMigration returns the most recently applied migration and its batch.
+// The name is empty when nothing has been migrated.
+func (m *Migration) LastMigration() (string, int, error) {
+	if err := m.EnsureMigrationsTable(); err != nil {
+		return "", 0, fmt.Errorf("failed to ensure migrations table: %w", err)
+	}
 
-		// Execute each SQL statement within transaction
-		for _, stmt := range statements {
-			if stmt == "" {
-				continue
-			}
-			if _, err := tx.Exec(stmt); err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to run migration %s: %w", name, err)
-			}
-		}
+	rows, err := m.DB.Query("SELECT migration, batch FROM migrations ORDER BY id DESC")
+	if err != nil {
+		return "", 0, err
+	}
+	defer rows.Close()
 
-		// Record migration within same transaction
-		query := fmt.Sprintf("INSERT INTO migrations (migration, batch) VALUES (%s, %s)", m.placeholder(1), m.placeholder(2))
-		if _, err := tx.Exec(query, name, batch); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record migration %s: %w", name, err)
+	var name string
+	var batch int
+	if rows.Next() {
+		if err := rows.Scan(&name, &batch); err != nil {
+			return "", 0, err
 		}
+	}
+
+	return name, batch, rows.Err()
+}
+
+// Reapply rolls back the most recently applied migration and runs the file
+// again in the same batch. The DOWN section is taken from previous when given,
+// so an edited file is reverted with the SQL that was originally applied.
+func (m *Migration) Reapply(filePath string, previous []byte) (err error) {
+	ctx, finish := m.startCommand("reapply")
+	defer func() { finish(err) }()
+
+	name := filepath.Base(filePath)
+
+	if err := m.EnsureMigrationsTable(); err != nil {
+		return fmt.Errorf("failed to ensure migrations table: %w", err)
+	}
+
+	// Acquire lock before checking, so nothing is applied in between
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+	defer m.releaseLock()
 
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %s: %w", name, err)
+	last, batch, err := m.LastMigration()
+	if err != nil {
+		return fmt.Errorf("failed to get last migration: %w", err)
+	}
+	if last != name {
+		return fmt.Errorf("migration %s is not the most recently applied migration", name)
+	}
+
+	if previous == nil {
+		if previous, err = os.ReadFile(filePath); err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", name, err)
 		}
+	}
 
-		executed++
+	if err := m.runDownContent(ctx, filePath, string(previous), batch); err != nil {
+		return err
 	}
+	color.Green("✓ Rolled back: %s", name)
+
+	if err := m.runUp(ctx, filePath, batch); err != nil {
+		return err
+	}
+	color.Green("✓ Migrated: %s", name)
 
 	return nil
 }
@@ -641,6 +1143,16 @@ type MigrationStatus struct {
 	Name     string
 	Migrated bool
 	Batch    int
+
+	// Warnings lists section problems for the active driver, e.g. a file
+	// with only --UP:postgres-- when running on SQLite.
+	Warnings []string
+
+	// Phase is PhaseExpand or PhaseContract. Tags and Depends come from the
+	// -- @tags and -- @depends headers.
+	Phase   string
+	Tags    []string
+	Depends []string
 }
 
 func (m *Migration) DryRun(migrationsPath string) error {
@@ -658,32 +1170,41 @@ func (m *Migration) DryRun(migrationsPath string) error {
 		return fmt.Errorf("failed to get next batch: %w", err)
 	}
 
-	files, err := m.getMigrationFiles(migrationsPath)
+	files, err := m.pendingMigrations(migrationsPath, migrated, m.Phase)
 	if err != nil {
-		return fmt.Errorf("failed to get migration files: %w", err)
+		return fmt.Errorf("failed to resolve migrations: %w", err)
 	}
 
 	pending := 0
 	color.Cyan("=== Dry Run - No changes will be made ===\n")
 
 	for _, file := range files {
-		name := filepath.Base(file)
+		name := file.name
 
-		if contains(migrated, name) {
-			continue
+		content, err := os.ReadFile(file.path)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", name, err)
 		}
 
-		statements, err := m.parseMigrationSQL(file, true)
+		statements, err := m.parseMigrationContent(string(content), true)
+		if errors.Is(err, ErrNoSection) {
+			color.Yellow("⚠ Would fail: %s (%v)", name, err)
+			pending++
+			continue
+		}
 		if err != nil {
 			return fmt.Errorf("failed to parse migration %s: %w", name, err)
 		}
 
 		color.Yellow("Would migrate: %s (Batch %d)", name, batch)
-		for i, stmt := range statements {
-			if stmt == "" {
-				continue
+		if section, err := m.sectionSQL(string(content), true); err == nil {
+			// Values often come from secrets, so only the names are shown
+			for _, name := range expand.Variables(section, m.Driver) {
+				color.White("  Variable: ${%s}", name)
 			}
-			color.White("  Statement %d: %s", i+1, truncateSQL(stmt, 80))
+		}
+		for _, stmt := range statements {
+			color.White("  Statement %d (line %d): %s", stmt.Index, stmt.StartLine, truncateSQL(stmt.SQL, 80))
 		}
 		pending++
 	}
@@ -714,95 +1235,70 @@ func truncateSQL(sql string, maxLen int) string {
 }
 
 func (m *Migration) Status(migrationsPath string) ([]MigrationStatus, error) {
-	if err := m.EnsureMigrationsTable(); err != nil {
-		return nil, fmt.Errorf("failed to ensure migrations table: %w", err)
-	}
-
-	files, err := m.getMigrationFiles(migrationsPath)
+	files, err := m.orderedMigrationFiles(migrationsPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get migration files: %w", err)
 	}
 
-	// Get all migrated migrations with their batch numbers
-	query := "SELECT migration, batch FROM migrations ORDER BY id"
-	rows, err := m.DB.Query(query)
+	// Status only reads; without a migrations table nothing has run yet
+	exists, err := m.tableExists("migrations")
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to check migrations table: %w", err)
 	}
-	defer rows.Close()
 
 	migratedMap := make(map[string]int)
-	for rows.Next() {
-		var name string
-		var batch int
-		if err := rows.Scan(&name, &batch); err != nil {
+	if exists {
+		migratedMap, err = m.getBatches()
+		if err != nil {
 			return nil, err
 		}
-		migratedMap[name] = batch
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
 	}
 
 	// Build status list
 	var statuses []MigrationStatus
 	for _, file := range files {
-		name := filepath.Base(file)
-		batch, migrated := migratedMap[name]
-		statuses = append(statuses, MigrationStatus{
-			Name:     name,
+		if !hasTag(file.directives.tags, m.Tags) || !file.directives.inPhase(m.Phase) {
+			continue
+		}
+
+		batch, migrated := migratedMap[file.name]
+		status := MigrationStatus{
+			Name:     file.name,
 			Migrated: migrated,
 			Batch:    batch,
-		})
+			Phase:    file.directives.phaseOf(),
+			Tags:     file.directives.tags,
+			Depends:  file.directives.depends,
+		}
+		if content, err := os.ReadFile(file.path); err == nil {
+			status.Warnings = m.sectionWarnings(string(content))
+		}
+		statuses = append(statuses, status)
 	}
 
 	return statuses, nil
 }
 
-func (m *Migration) parseMigrationSQL(filePath string, isUp bool) ([]string, error) {
-	content, err := os.ReadFile(filePath)
+// parseMigrationContent returns the statements of the UP or DOWN section
+// for the active driver, numbered and with their file lines.
+func (m *Migration) parseMigrationContent(text string, isUp bool) ([]statement.Statement, error) {
+	parts, err := m.sectionParts(text, isUp)
 	if err != nil {
 		return nil, err
 	}
 
-	text := string(content)
-
-	// Find --UP-- and --DOWN-- sections
-	upMarker := "--UP--"
-	downMarker := "--DOWN--"
-
-	upIndex := strings.Index(text, upMarker)
-	downIndex := strings.Index(text, downMarker)
-
-	if upIndex == -1 || downIndex == -1 {
-		return nil, fmt.Errorf("migration file must contain both --UP-- and --DOWN-- sections")
-	}
-
-	var sql string
-	if isUp {
-		// Extract SQL between --UP-- and --DOWN--
-		sql = text[upIndex+len(upMarker) : downIndex]
-	} else {
-		// Extract SQL after --DOWN--
-		sql = text[downIndex+len(downMarker):]
-	}
-
-	// Trim whitespace
-	sql = strings.TrimSpace(sql)
-
-	// Split by semicolon to get individual statements
-	statements := strings.Split(sql, ";")
+	var statements []statement.Statement
+	for _, part := range parts {
+		sql, err := expand.SQL(part.body, m.Driver, m.Variables)
+		if err != nil {
+			return nil, err
+		}
 
-	// Trim each statement and filter out empty ones
-	var result []string
-	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		// Skip empty statements and comment-only lines
-		if stmt != "" && !strings.HasPrefix(stmt, "--") {
-			result = append(result, stmt)
+		for _, stmt := range statement.Split(sql, canonicalDriver(m.Driver), part.line) {
+			stmt.Index = len(statements) + 1
+			statements = append(statements, stmt)
 		}
 	}
 
-	return result, nil
+	return statements, nil
 }
@@ -0,0 +1,183 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// ErrLaterMigrations is returned when a targeted rollback would revert a
+// migration that later migrations were applied on top of. Any of them may
+// rely on it; the ones that say so with -- @depends are named in the error.
+var ErrLaterMigrations = errors.New("later migrations may depend on the target")
+
+type appliedMigration struct {
+	ID    int64
+	Name  string
+	Batch int
+}
+
+// RollbackBatch reverts every migration in the given batch, which does not
+// have to be the last one. Unless force is set it refuses when migrations
+// from later batches are still applied.
+func (m *Migration) RollbackBatch(migrationsPath string, batch int, force bool) (err error) {
+	ctx, finish := m.startCommand("rollback_batch")
+	defer func() { finish(err) }()
+
+	if err := m.guardDestructive("rollback"); err != nil {
+		return err
+	}
+
+	if err := m.EnsureMigrationsTable(); err != nil {
+		return fmt.Errorf("failed to ensure migrations table: %w", err)
+	}
+
+	// Acquire lock to prevent concurrent migrations
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+	defer m.releaseLock()
+
+	applied, err := m.getApplied()
+	if err != nil {
+		return fmt.Errorf("failed to get migrated list: %w", err)
+	}
+
+	var targets, later []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		switch {
+		case applied[i].Batch == batch:
+			targets = append(targets, applied[i].Name)
+		case applied[i].Batch > batch:
+			later = append(later, applied[i].Name)
+		}
+	}
+
+	if len(targets) == 0 {
+		color.Cyan("Nothing to rollback in batch %d.", batch)
+		return nil
+	}
+
+	var dependents []string
+	for _, target := range targets {
+		dependents = append(dependents, m.dependentsOf(migrationsPath, target)...)
+	}
+	if err := checkLater(fmt.Sprintf("batch %d", batch), later, dependents, force); err != nil {
+		return err
+	}
+	if err := m.guardBatch(migrationsPath, targets); err != nil {
+		return err
+	}
+
+	return m.rollbackMigrations(ctx, migrationsPath, targets, batch)
+}
+
+// RollbackFile reverts a single applied migration, e.g. one that was run with
+// MigrateFile. Unless force is set it refuses when other migrations were
+// applied after it.
+func (m *Migration) RollbackFile(filePath string, force bool) (err error) {
+	ctx, finish := m.startCommand("rollback_file")
+	defer func() { finish(err) }()
+
+	if err := m.guardDestructive("rollback"); err != nil {
+		return err
+	}
+
+	if err := m.EnsureMigrationsTable(); err != nil {
+		return fmt.Errorf("failed to ensure migrations table: %w", err)
+	}
+
+	// Acquire lock to prevent concurrent migrations
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+	defer m.releaseLock()
+
+	// A named file must exist; rollbackMigrations would otherwise treat it
+	// as deleted and remove the record without running DOWN
+	if _, err := os.Stat(filePath); err != nil {
+		return fmt.Errorf("failed to find migration: %w", err)
+	}
+
+	name := filepath.Base(filePath)
+
+	applied, err := m.getApplied()
+	if err != nil {
+		return fmt.Errorf("failed to get migrated list: %w", err)
+	}
+
+	var target *appliedMigration
+	var later []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		if applied[i].Name == name {
+			target = &applied[i]
+			break
+		}
+		later = append(later, applied[i].Name)
+	}
+
+	if target == nil {
+		color.Yellow("⚠ Migration not applied: %s", name)
+		return nil
+	}
+
+	if err := checkLater(name, later, m.dependentsOf(filepath.Dir(filePath), name), force); err != nil {
+		return err
+	}
+	if err := m.guardBatch(filepath.Dir(filePath), []string{name}); err != nil {
+		return err
+	}
+
+	return m.rollbackMigrations(ctx, filepath.Dir(filePath), []string{name}, target.Batch)
+}
+
+func checkLater(target string, later, dependents []string, force bool) error {
+	if len(later) == 0 {
+		return nil
+	}
+
+	if force {
+		color.Yellow("⚠ Rolling back %s although %d later migration(s) are applied", target, len(later))
+		return nil
+	}
+
+	var declared []string
+	for _, name := range later {
+		if contains(dependents, name) {
+			declared = append(declared, name)
+		}
+	}
+	if len(declared) > 0 {
+		return fmt.Errorf("%w: cannot roll back %s while %s are applied, and %s declare @depends on it (use --ignore-later to override)", ErrLaterMigrations, target, strings.Join(later, ", "), strings.Join(declared, ", "))
+	}
+
+	return fmt.Errorf("%w: cannot roll back %s while %s are applied (use --ignore-later to override)", ErrLaterMigrations, target, strings.Join(later, ", "))
+}
+
+// getApplied lists applied migrations in the order they were run.
+func (m *Migration) getApplied() ([]appliedMigration, error) {
+	if err := m.EnsureMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("failed to ensure migrations table: %w", err)
+	}
+
+	rows, err := m.DB.Query("SELECT id, migration, batch FROM migrations ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []appliedMigration
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.ID, &a.Name, &a.Batch); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+
+	return applied, rows.Err()
+}
@@ -0,0 +1,156 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrNoSection is returned when a migration has no UP or DOWN section that
+// applies to the active driver.
+var ErrNoSection = errors.New("no section for driver")
+
+// sectionMarker matches --UP--, --DOWN-- and driver scoped markers such as
+// --UP:postgres-- or --DOWN:mysql,sqlite-- at the start of a line. SQL may
+// follow the marker on the same line.
+var sectionMarker = regexp.MustCompile(`(?m)^[ \t]*--(UP|DOWN)(?::([A-Za-z0-9_,]+))?--`)
+
+var knownDrivers = map[string]bool{"mysql": true, "postgres": true, "sqlite": true, "sqlserver": true}
+
+type section struct {
+	up      bool
+	drivers []string // empty for the generic section
+	marker  string
+	body    string
+	line    int // file line of the marker, where body starts
+}
+
+// parseSections splits a migration into its marked sections.
+func parseSections(text string) []section {
+	matches := sectionMarker.FindAllStringSubmatchIndex(text, -1)
+
+	var sections []section
+	for i, match := range matches {
+		end := len(text)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+
+		s := section{
+			up:     text[match[2]:match[3]] == "UP",
+			marker: strings.TrimSpace(text[match[0]:match[1]]),
+			body:   text[match[1]:end],
+			line:   strings.Count(text[:match[0]], "\n") + 1,
+		}
+		if match[4] != -1 {
+			for _, driver := range strings.Split(text[match[4]:match[5]], ",") {
+				if driver != "" {
+					s.drivers = append(s.drivers, canonicalDriver(driver))
+				}
+			}
+		}
+		sections = append(sections, s)
+	}
+
+	return sections
+}
+
+// canonicalDriver folds driver aliases so sqlite3 matches --UP:sqlite-- and
+// mssql matches --UP:sqlserver--.
+func canonicalDriver(driver string) string {
+	switch driver = strings.ToLower(driver); driver {
+	case "sqlite3":
+		return "sqlite"
+	case "mssql":
+		return "sqlserver"
+	case "":
+		return "mysql"
+	}
+	return driver
+}
+
+// sectionSQL returns the body of the UP or DOWN section for the active
+// driver. A driver scoped section wins over the generic one.
+func (m *Migration) sectionSQL(text string, isUp bool) (string, error) {
+	parts, err := m.sectionParts(text, isUp)
+	if err != nil {
+		return "", err
+	}
+
+	bodies := make([]string, len(parts))
+	for i, part := range parts {
+		bodies[i] = part.body
+	}
+	return strings.Join(bodies, "\n"), nil
+}
+
+// sectionParts returns the sections that make up sectionSQL, which keep
+// their line numbers.
+func (m *Migration) sectionParts(text string, isUp bool) ([]section, error) {
+	sections := parseSections(text)
+
+	hasUp, hasDown := false, false
+	for _, s := range sections {
+		if s.up {
+			hasUp = true
+		} else {
+			hasDown = true
+		}
+	}
+	if !hasUp || !hasDown {
+		return nil, fmt.Errorf("migration file must contain both --UP-- and --DOWN-- sections")
+	}
+
+	driver := canonicalDriver(m.Driver)
+	var generic []section
+	var scoped []section
+	for _, s := range sections {
+		if s.up != isUp {
+			continue
+		}
+		if len(s.drivers) == 0 {
+			generic = append(generic, s)
+		} else if contains(s.drivers, driver) {
+			scoped = append(scoped, s)
+		}
+	}
+
+	if len(scoped) > 0 {
+		return scoped, nil
+	}
+	if len(generic) > 0 {
+		return generic, nil
+	}
+
+	direction := "DOWN"
+	if isUp {
+		direction = "UP"
+	}
+	return nil, fmt.Errorf("%w %s: missing --%s-- or --%s:%s--", ErrNoSection, driver, direction, direction, driver)
+}
+
+// sectionWarnings reports problems with a migration's sections for the
+// active driver without failing.
+func (m *Migration) sectionWarnings(text string) []string {
+	var warnings []string
+
+	for _, s := range parseSections(text) {
+		for _, driver := range s.drivers {
+			if !knownDrivers[driver] {
+				warnings = append(warnings, fmt.Sprintf("unknown driver %q in %s", driver, s.marker))
+			}
+		}
+	}
+
+	for _, isUp := range []bool{true, false} {
+		if _, err := m.sectionSQL(text, isUp); err != nil {
+			warnings = append(warnings, err.Error())
+			if !errors.Is(err, ErrNoSection) {
+				break
+			}
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,245 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrShardSkipped is the result for shards that were not started because
+// an earlier shard or wave failed.
+var ErrShardSkipped = errors.New("skipped after an earlier shard failed")
+
+type RolloutOptions struct {
+	// Connect opens the database for one shard. The pool is closed when the
+	// shard is done.
+	Connect func(shard string) (*sql.DB, error)
+
+	// WaveSize is how many shards run in parallel after the canary. Zero
+	// runs all remaining shards in a single wave.
+	WaveSize int
+
+	// OnWave is called before each wave starts. Wave 0 is the canary.
+	OnWave func(wave int, shards []string)
+}
+
+type ShardResult struct {
+	Shard    string
+	Wave     int
+	Err      error
+	Duration time.Duration
+
+	// Statuses is only set by StatusShards.
+	Statuses []MigrationStatus
+}
+
+// ShardDrift describes a shard whose applied migrations differ from the
+// set applied on most shards.
+type ShardDrift struct {
+	Shard   string
+	Missing []string
+	Extra   []string
+}
+
+// RolloutMigrate runs Migrate on the first shard as a canary, then on the
+// remaining shards in parallel waves. The waves only apply migrations that
+// are applied on the canary, so no shard runs anything it did not validate.
+// Nothing more is started after a failure; the rest of the shards are
+// reported as ErrShardSkipped.
+func (m *Migration) RolloutMigrate(migrationsPath string, shards []string, opts RolloutOptions) []ShardResult {
+	var validated []string
+	return m.rollout(shards, opts, true, func(s *Migration, result *ShardResult) error {
+		if result.Wave > 0 {
+			s.only = validated
+			return s.Migrate(migrationsPath)
+		}
+
+		if err := s.Migrate(migrationsPath); err != nil {
+			return err
+		}
+		migrated, err := s.getMigrated()
+		if err != nil {
+			return fmt.Errorf("failed to get migrated list: %w", err)
+		}
+		validated = append([]string{}, migrated...)
+		return nil
+	})
+}
+
+// RolloutRollback rolls back the last batch of every shard, canary first.
+func (m *Migration) RolloutRollback(migrationsPath string, shards []string, opts RolloutOptions) []ShardResult {
+	return m.rollout(shards, opts, true, func(s *Migration, result *ShardResult) error {
+		return s.Rollback(migrationsPath)
+	})
+}
+
+// StatusShards reads the status of every shard, WaveSize at a time. Use
+// Drift on the results to find shards that are out of step.
+func (m *Migration) StatusShards(migrationsPath string, shards []string, opts RolloutOptions) []ShardResult {
+	return m.rollout(shards, opts, false, func(s *Migration, result *ShardResult) (err error) {
+		result.Statuses, err = s.Status(migrationsPath)
+		return err
+	})
+}
+
+func (m *Migration) rollout(shards []string, opts RolloutOptions, canary bool, fn func(s *Migration, result *ShardResult) error) []ShardResult {
+	results := make([]ShardResult, len(shards))
+	for i, shard := range shards {
+		results[i] = ShardResult{Shard: shard, Err: ErrShardSkipped}
+	}
+
+	for wave, indexes := range rolloutWaves(len(shards), opts.WaveSize, canary) {
+		if opts.OnWave != nil {
+			names := make([]string, len(indexes))
+			for i, index := range indexes {
+				names[i] = shards[index]
+			}
+			opts.OnWave(wave, names)
+		}
+
+		var wg sync.WaitGroup
+		for _, index := range indexes {
+			wg.Add(1)
+			go func(result *ShardResult) {
+				defer wg.Done()
+
+				result.Wave = wave
+				started := time.Now()
+				result.Err = m.withDB(opts.Connect, result.Shard, func(s *Migration) error {
+					return fn(s, result)
+				})
+				result.Duration = time.Since(started)
+			}(&results[index])
+		}
+		wg.Wait()
+
+		// Only rollouts halt; a status read carries on past unreachable shards
+		if canary && failedInWave(results, indexes) {
+			break
+		}
+	}
+
+	return results
+}
+
+// rolloutWaves splits shard indexes into waves, optionally starting with the
+// first shard on its own.
+func rolloutWaves(count, size int, canary bool) [][]int {
+	var waves [][]int
+	start := 0
+	if canary && count > 0 {
+		waves = append(waves, []int{0})
+		start = 1
+	}
+
+	if size < 1 {
+		size = count
+	}
+	for i := start; i < count; i += size {
+		var wave []int
+		for j := i; j < i+size && j < count; j++ {
+			wave = append(wave, j)
+		}
+		waves = append(waves, wave)
+	}
+	return waves
+}
+
+func failedInWave(results []ShardResult, indexes []int) bool {
+	for _, index := range indexes {
+		if results[index].Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// withDB runs fn against a copy of m connected with connect.
+func (m *Migration) withDB(connect func(string) (*sql.DB, error), name string, fn func(*Migration) error) error {
+	if connect == nil {
+		return fmt.Errorf("no connector configured")
+	}
+
+	db, err := connect(name)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", name, err)
+	}
+	defer db.Close()
+
+	// Same settings, different database
+	scoped := *m
+	scoped.DB = db
+
+	return fn(&scoped)
+}
+
+// Drift compares the applied migrations of every shard read successfully
+// and returns the shards that differ from the most common set. Ties go to
+// the set seen first.
+func Drift(results []ShardResult) []ShardDrift {
+	applied := make(map[string]map[string]bool)
+	counts := make(map[string]int)
+	keys := make(map[string]string)
+	var majority string
+
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+
+		set := make(map[string]bool)
+		var names []string
+		for _, status := range result.Statuses {
+			if status.Migrated {
+				set[status.Name] = true
+				names = append(names, status.Name)
+			}
+		}
+		sort.Strings(names)
+		key := strings.Join(names, "\n")
+
+		applied[result.Shard] = set
+		keys[result.Shard] = key
+		counts[key]++
+		if counts[key] > counts[majority] || len(counts) == 1 {
+			majority = key
+		}
+	}
+
+	var expected map[string]bool
+	for shard, key := range keys {
+		if key == majority {
+			expected = applied[shard]
+			break
+		}
+	}
+
+	var drift []ShardDrift
+	for _, result := range results {
+		set, ok := applied[result.Shard]
+		if !ok || keys[result.Shard] == majority {
+			continue
+		}
+
+		d := ShardDrift{Shard: result.Shard}
+		for name := range expected {
+			if !set[name] {
+				d.Missing = append(d.Missing, name)
+			}
+		}
+		for name := range set {
+			if !expected[name] {
+				d.Extra = append(d.Extra, name)
+			}
+		}
+		sort.Strings(d.Missing)
+		sort.Strings(d.Extra)
+		drift = append(drift, d)
+	}
+
+	return drift
+}
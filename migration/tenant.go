@@ -0,0 +1,111 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTenantSkipped is the result for tenants that were not started because
+// an earlier tenant failed and ContinueOnError is off.
+var ErrTenantSkipped = errors.New("skipped after an earlier tenant failed")
+
+type TenantOptions struct {
+	// Connect opens the database for one tenant, e.g. a PostgreSQL pool with
+	// search_path set to the tenant's schema or the tenant's SQLite file.
+	// The pool is closed when the tenant is done.
+	Connect func(tenant string) (*sql.DB, error)
+
+	// Concurrency is how many tenants run at once. Zero means one.
+	Concurrency int
+
+	// ContinueOnError keeps going with the remaining tenants after one fails.
+	ContinueOnError bool
+}
+
+type TenantResult struct {
+	Tenant   string
+	Err      error
+	Duration time.Duration
+
+	// Statuses is only set by StatusTenants.
+	Statuses []MigrationStatus
+}
+
+// MigrateTenants runs Migrate for every tenant, each with its own
+// migrations, lock and history tables.
+func (m *Migration) MigrateTenants(migrationsPath string, tenants []string, opts TenantOptions) []TenantResult {
+	return m.forEachTenant(tenants, opts, func(t *Migration, result *TenantResult) error {
+		return t.Migrate(migrationsPath)
+	})
+}
+
+// RollbackTenants rolls back the last batch of every tenant.
+func (m *Migration) RollbackTenants(migrationsPath string, tenants []string, opts TenantOptions) []TenantResult {
+	return m.forEachTenant(tenants, opts, func(t *Migration, result *TenantResult) error {
+		return t.Rollback(migrationsPath)
+	})
+}
+
+func (m *Migration) StatusTenants(migrationsPath string, tenants []string, opts TenantOptions) []TenantResult {
+	return m.forEachTenant(tenants, opts, func(t *Migration, result *TenantResult) (err error) {
+		result.Statuses, err = t.Status(migrationsPath)
+		return err
+	})
+}
+
+func (m *Migration) forEachTenant(tenants []string, opts TenantOptions, fn func(t *Migration, result *TenantResult) error) []TenantResult {
+	results := make([]TenantResult, len(tenants))
+	for i, tenant := range tenants {
+		results[i] = TenantResult{Tenant: tenant, Err: ErrTenantSkipped}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	failed := false
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for i := range tenants {
+		slots <- struct{}{}
+
+		mu.Lock()
+		stop := failed && !opts.ContinueOnError
+		mu.Unlock()
+		if stop {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(result *TenantResult) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			started := time.Now()
+			result.Err = m.runTenant(result, opts, fn)
+			result.Duration = time.Since(started)
+
+			if result.Err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(&results[i])
+	}
+
+	wg.Wait()
+	return results
+}
+
+func (m *Migration) runTenant(result *TenantResult, opts TenantOptions, fn func(t *Migration, result *TenantResult) error) error {
+	return m.withDB(opts.Connect, result.Tenant, func(t *Migration) error {
+		return fn(t, result)
+	})
+}
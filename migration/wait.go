@@ -0,0 +1,35 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hymns/go-artisan/retry"
+)
+
+// waitPolicy starts with short pings and settles at one every 5s.
+var waitPolicy = retry.Policy{Backoff: 250 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// WaitForDB pings db until it accepts queries or timeout passes, logging each
+// failed attempt. It is meant for databases that start alongside the
+// application, e.g. in docker-compose or a Kubernetes init container.
+func WaitForDB(db *sql.DB, timeout time.Duration) error {
+	attempts := 0
+	err := waitPolicy.Wait(context.Background(), timeout, func(ctx context.Context, attempt int) error {
+		attempts = attempt
+		return db.PingContext(ctx)
+	}, func(attempt int, err error, delay time.Duration) {
+		color.Yellow("⚠ Database not ready (attempt %d): %v; retrying in %s", attempt, err, delay.Round(time.Millisecond))
+	})
+	if err != nil {
+		return fmt.Errorf("database not ready after %s (%d attempts): %w", timeout, attempts, err)
+	}
+
+	if attempts > 1 {
+		color.Green("✓ Database ready after %d attempts", attempts)
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/hymns/go-artisan/history"
+)
+
+type WipeOptions struct {
+	DropViews bool
+	DropTypes bool // PostgreSQL enum/domain/composite types and SQL Server user-defined types
+}
+
+type wipeObject struct {
+	kind string // TABLE, VIEW, TYPE, DOMAIN, SEQUENCE
+	name string
+}
+
+// Wipe drops every table in the current database, including ones that were
+// not created by migrations and the migrations table itself. Only the
+// migration_history audit log and the migration_lock table are kept, and the
+// lock is held while tables are dropped. Views and types are only dropped when
+// requested. Unlike rolling back batch by batch, it does
+// not depend on DOWN sections or migration files.
+func (m *Migration) Wipe(opts WipeOptions) (err error) {
+	ctx, finish := m.startCommand("wipe")
+	defer func() { finish(err) }()
+
+	if err := m.guardDestructive("wipe"); err != nil {
+		return err
+	}
+
+	if err := m.EnsureMigrationsTable(); err != nil {
+		return fmt.Errorf("failed to ensure migrations table: %w", err)
+	}
+
+	// Acquire lock so a migration on another host is not wiped out mid-batch
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+	defer m.releaseLock()
+
+	// Session settings such as foreign key checks only apply to one connection
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	var objects []wipeObject
+	var before, after []string
+
+	switch m.Driver {
+	case "postgres":
+		objects, err = m.postgresObjects(ctx, conn, opts)
+	case "sqlite", "sqlite3":
+		objects, err = m.sqliteObjects(ctx, conn, opts)
+		before = []string{"PRAGMA foreign_keys = OFF"}
+		after = []string{"PRAGMA foreign_keys = ON"}
+	case "sqlserver", "mssql":
+		before, err = m.sqlserverForeignKeys(ctx, conn)
+		if err == nil {
+			objects, err = m.sqlserverObjects(ctx, conn, opts)
+		}
+	default: // mysql
+		objects, err = m.mysqlObjects(ctx, conn, opts)
+		before = []string{"SET FOREIGN_KEY_CHECKS = 0"}
+		after = []string{"SET FOREIGN_KEY_CHECKS = 1"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list database objects: %w", err)
+	}
+
+	for _, stmt := range before {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to prepare wipe: %w", err)
+		}
+	}
+
+	// Restore foreign key checks even when a drop fails, so the pooled
+	// connection is not handed back with them disabled
+	defer func() {
+		for _, stmt := range after {
+			if _, afterErr := conn.ExecContext(ctx, stmt); afterErr != nil && err == nil {
+				err = fmt.Errorf("failed to finish wipe: %w", afterErr)
+			}
+		}
+	}()
+
+	for _, object := range objects {
+		// The audit log survives a wipe so it keeps a record of it, and the
+		// lock table so the lock is still held
+		if object.kind == "TABLE" && isKeptTable(object.name) {
+			continue
+		}
+
+		stmt := m.dropStatement(object)
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to drop %s %s: %w", strings.ToLower(object.kind), object.name, err)
+		}
+	}
+
+	if err := history.Record(m.DB, m.Driver, history.Entry{Migration: "*", Direction: history.DirectionWipe, Outcome: history.OutcomeSuccess}); err != nil {
+		color.Yellow("⚠ Failed to write migration history for wipe: %v", err)
+	}
+
+	return nil
+}
+
+func isKeptTable(name string) bool {
+	// SQL Server names come back schema-qualified, e.g. [dbo].[migration_history]
+	name = strings.Trim(name[strings.LastIndex(name, ".")+1:], "[]")
+	return name == history.TableName || name == "migration_lock"
+}
+
+func (m *Migration) dropStatement(object wipeObject) string {
+	switch m.Driver {
+	case "postgres":
+		return fmt.Sprintf("DROP %s IF EXISTS %s CASCADE", object.kind, m.quoteIdent(object.name))
+	case "sqlserver", "mssql":
+		// SQL Server names are already schema-qualified and quoted by the query
+		return fmt.Sprintf("DROP %s IF EXISTS %s", object.kind, object.name)
+	default:
+		return fmt.Sprintf("DROP %s IF EXISTS %s", object.kind, m.quoteIdent(object.name))
+	}
+}
+
+func (m *Migration) quoteIdent(name string) string {
+	switch m.Driver {
+	case "postgres", "sqlite", "sqlite3":
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	case "sqlserver", "mssql":
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+	default: // mysql
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+}
+
+func (m *Migration) mysqlObjects(ctx context.Context, conn *sql.Conn, opts WipeOptions) ([]wipeObject, error) {
+	var objects []wipeObject
+
+	if opts.DropViews {
+		views, err := queryNames(ctx, conn, "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'VIEW'")
+		if err != nil {
+			return nil, err
+		}
+		objects = appendObjects(objects, "VIEW", views)
+	}
+
+	tables, err := queryNames(ctx, conn, "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'")
+	if err != nil {
+		return nil, err
+	}
+
+	return appendObjects(objects, "TABLE", tables), nil
+}
+
+func (m *Migration) postgresObjects(ctx context.Context, conn *sql.Conn, opts WipeOptions) ([]wipeObject, error) {
+	var objects []wipeObject
+
+	if opts.DropViews {
+		views, err := queryNames(ctx, conn, "SELECT viewname FROM pg_views WHERE schemaname = current_schema()")
+		if err != nil {
+			return nil, err
+		}
+		objects = appendObjects(objects, "VIEW", views)
+
+		matViews, err := queryNames(ctx, conn, "SELECT matviewname FROM pg_matviews WHERE schemaname = current_schema()")
+		if err != nil {
+			return nil, err
+		}
+		objects = appendObjects(objects, "MATERIALIZED VIEW", matViews)
+	}
+
+	tables, err := queryNames(ctx, conn, "SELECT tablename FROM pg_tables WHERE schemaname = current_schema()")
+	if err != nil {
+		return nil, err
+	}
+	objects = appendObjects(objects, "TABLE", tables)
+
+	// Sequences owned by a table are gone by now; IF EXISTS covers those.
+	// The id sequences of the audit log and lock table are kept with them.
+	sequences, err := queryNames(ctx, conn, fmt.Sprintf(`SELECT s.relname FROM pg_class s
+		JOIN pg_namespace n ON n.oid = s.relnamespace
+		WHERE s.relkind = 'S' AND n.nspname = current_schema()
+		AND NOT EXISTS (SELECT 1 FROM pg_depend d JOIN pg_class t ON t.oid = d.refobjid
+			WHERE d.classid = 'pg_class'::regclass AND d.objid = s.oid
+			AND d.deptype IN ('a', 'i') AND t.relname IN ('%s', 'migration_lock'))`, history.TableName))
+	if err != nil {
+		return nil, err
+	}
+	objects = appendObjects(objects, "SEQUENCE", sequences)
+
+	if opts.DropTypes {
+		types, err := queryNames(ctx, conn, `SELECT t.typname FROM pg_type t
+			JOIN pg_namespace n ON n.oid = t.typnamespace
+			WHERE n.nspname = current_schema()
+			AND (t.typtype = 'e' OR (t.typtype = 'c' AND (SELECT c.relkind FROM pg_class c WHERE c.oid = t.typrelid) = 'c'))`)
+		if err != nil {
+			return nil, err
+		}
+		objects = appendObjects(objects, "TYPE", types)
+
+		domains, err := queryNames(ctx, conn, `SELECT t.typname FROM pg_type t
+			JOIN pg_namespace n ON n.oid = t.typnamespace
+			WHERE n.nspname = current_schema() AND t.typtype = 'd'`)
+		if err != nil {
+			return nil, err
+		}
+		objects = appendObjects(objects, "DOMAIN", domains)
+	}
+
+	return objects, nil
+}
+
+func (m *Migration) sqliteObjects(ctx context.Context, conn *sql.Conn, opts WipeOptions) ([]wipeObject, error) {
+	var objects []wipeObject
+
+	if opts.DropViews {
+		views, err := queryNames(ctx, conn, "SELECT name FROM sqlite_master WHERE type = 'view'")
+		if err != nil {
+			return nil, err
+		}
+		objects = appendObjects(objects, "VIEW", views)
+	}
+
+	tables, err := queryNames(ctx, conn, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, err
+	}
+
+	return appendObjects(objects, "TABLE", tables), nil
+}
+
+func (m *Migration) sqlserverObjects(ctx context.Context, conn *sql.Conn, opts WipeOptions) ([]wipeObject, error) {
+	var objects []wipeObject
+
+	if opts.DropViews {
+		views, err := queryNames(ctx, conn, "SELECT QUOTENAME(s.name) + '.' + QUOTENAME(v.name) FROM sys.views v JOIN sys.schemas s ON v.schema_id = s.schema_id")
+		if err != nil {
+			return nil, err
+		}
+		objects = appendObjects(objects, "VIEW", views)
+	}
+
+	tables, err := queryNames(ctx, conn, "SELECT QUOTENAME(s.name) + '.' + QUOTENAME(t.name) FROM sys.tables t JOIN sys.schemas s ON t.schema_id = s.schema_id")
+	if err != nil {
+		return nil, err
+	}
+	objects = appendObjects(objects, "TABLE", tables)
+
+	if opts.DropTypes {
+		types, err := queryNames(ctx, conn, "SELECT QUOTENAME(s.name) + '.' + QUOTENAME(t.name) FROM sys.types t JOIN sys.schemas s ON t.schema_id = s.schema_id WHERE t.is_user_defined = 1")
+		if err != nil {
+			return nil, err
+		}
+		objects = appendObjects(objects, "TYPE", types)
+	}
+
+	return objects, nil
+}
+
+// sqlserverForeignKeys builds statements that drop every foreign key, since
+// SQL Server has no session switch to disable them.
+func (m *Migration) sqlserverForeignKeys(ctx context.Context, conn *sql.Conn) ([]string, error) {
+	return queryNames(ctx, conn, `SELECT 'ALTER TABLE ' + QUOTENAME(s.name) + '.' + QUOTENAME(t.name) + ' DROP CONSTRAINT ' + QUOTENAME(f.name)
+		FROM sys.foreign_keys f
+		JOIN sys.tables t ON f.parent_object_id = t.object_id
+		JOIN sys.schemas s ON t.schema_id = s.schema_id`)
+}
+
+func queryNames(ctx context.Context, conn *sql.Conn, query string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+func appendObjects(objects []wipeObject, kind string, names []string) []wipeObject {
+	for _, name := range names {
+		objects = append(objects, wipeObject{kind: kind, name: name})
+	}
+	return objects
+}
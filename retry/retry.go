@@ -0,0 +1,115 @@
+// Package retry re-runs operations that failed with a transient error,
+// waiting longer between each attempt.
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// Policy controls how often and how long to retry. The zero value tries once.
+type Policy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+
+	// Backoff is the delay before the second attempt. It doubles for every
+	// attempt after that, up to MaxBackoff. Zero means 100ms.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay. Zero means 10s.
+	MaxBackoff time.Duration
+}
+
+// Enabled reports whether the policy allows more than one attempt.
+func (p Policy) Enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+// Delay returns how long to wait after the given failed attempt (1-based).
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.Backoff
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+	limit := p.MaxBackoff
+	if limit <= 0 {
+		limit = 10 * time.Second
+	}
+
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// Do runs fn until it succeeds, returns an error that retryable rejects, or
+// MaxAttempts is reached. onRetry, when set, is called before each wait.
+func (p Policy) Do(ctx context.Context, fn func(attempt int) error, retryable func(error) bool, onRetry func(attempt int, err error, delay time.Duration)) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(attempt); err == nil {
+			return nil
+		}
+		if attempt == attempts || retryable == nil || !retryable(err) {
+			return err
+		}
+
+		delay := p.Delay(attempt)
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+		if err := Sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// Sleep waits for d or until ctx is done.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Wait runs fn until it succeeds or timeout passes, however many attempts
+// that takes. MaxAttempts is ignored. The last error is returned on timeout.
+func (p Policy) Wait(ctx context.Context, timeout time.Duration, fn func(ctx context.Context, attempt int) error, onRetry func(attempt int, err error, delay time.Duration)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx, attempt)
+		if err == nil {
+			return nil
+		}
+
+		delay := p.Delay(attempt)
+		if remaining := time.Until(deadline); remaining <= 0 {
+			return err
+		} else if delay > remaining {
+			delay = remaining
+		}
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+		if Sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
@@ -1,21 +1,55 @@
 package seeder
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/hymns/go-artisan/expand"
+	"github.com/hymns/go-artisan/history"
+	"github.com/hymns/go-artisan/retry"
+	"github.com/hymns/go-artisan/statement"
+	"github.com/hymns/go-artisan/stub"
+	"github.com/hymns/go-artisan/telemetry"
 )
 
 type Seeder struct {
 	DB     *sql.DB
 	Driver string
+
+	// Instrumentation receives spans and metrics. Nil disables it.
+	Instrumentation telemetry.Instrumentation
+
+	// StubsPath is checked for a seeder.stub before the built-in template.
+	StubsPath string
+
+	// Variables resolve ${NAME} references before the environment does.
+	Variables map[string]string
+
+	// Retry re-runs a seeder's transaction when it fails with a transient
+	// error such as a deadlock or a dropped connection. MySQL seeders are
+	// not retried once a DDL statement has committed implicitly.
+	Retry retry.Policy
+
+	// InitStatements run at the start of every seeder's transaction, e.g.
+	// "SET ROLE migrator".
+	InitStatements []string
+
+	// Timeout limits each seeder. The transaction is rolled back and
+	// ErrTimeout returned when it runs out. Zero means no limit.
+	Timeout time.Duration
 }
 
+// ErrTimeout is returned when a seeder runs longer than Seeder.Timeout.
+var ErrTimeout = errors.New("seeder timed out")
+
 func New(db *sql.DB) *Seeder {
 	return &Seeder{
 		DB:     db,
@@ -37,6 +71,28 @@ func getDBDriver(db *sql.DB) string {
 	return "mysql" // default
 }
 
+// tableExists reports whether the named table exists, without creating it.
+func (s *Seeder) tableExists(name string) (bool, error) {
+	var query string
+
+	switch s.Driver {
+	case "postgres":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	case "sqlite", "sqlite3":
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	case "sqlserver", "mssql":
+		query = "SELECT COUNT(*) FROM sysobjects WHERE name = @p1 AND xtype = 'U'"
+	default: // mysql
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	}
+
+	var count int
+	if err := s.DB.QueryRow(query, name).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func (s *Seeder) EnsureSeedersTable() error {
 	var query string
 
@@ -92,15 +148,17 @@ func (s *Seeder) getSeeded() ([]string, error) {
 }
 
 func (s *Seeder) recordSeeder(name string) error {
-	query := "INSERT INTO seeders (seeder) VALUES (?)"
+	_, err := s.DB.Exec(s.recordQuery(), name)
+	return err
+}
+
+func (s *Seeder) recordQuery() string {
 	if s.Driver == "postgres" {
-		query = "INSERT INTO seeders (seeder) VALUES ($1)"
+		return "INSERT INTO seeders (seeder) VALUES ($1)"
 	} else if s.Driver == "sqlserver" || s.Driver == "mssql" {
-		query = "INSERT INTO seeders (seeder) VALUES (@p1)"
+		return "INSERT INTO seeders (seeder) VALUES (@p1)"
 	}
-
-	_, err := s.DB.Exec(query, name)
-	return err
+	return "INSERT INTO seeders (seeder) VALUES (?)"
 }
 
 func contains(slice []string, item string) bool {
@@ -112,29 +170,135 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-func (s *Seeder) RunFile(filePath string) error {
+func (s *Seeder) RunFile(filePath string) (err error) {
+	ctx, finish := s.startCommand("seed_file")
+	defer func() { finish(err) }()
+
+	if err := s.runSeeder(ctx, filePath, false); err != nil {
+		return err
+	}
+
+	color.Green("✓ Seeded: %s", filepath.Base(filePath))
+	return nil
+}
+
+// runSeeder executes a seeder file inside a transaction. When record is true
+// the seeder is also written to the seeders table within the same transaction.
+func (s *Seeder) runSeeder(ctx context.Context, filePath string, record bool) (err error) {
 	name := filepath.Base(filePath)
+	inst := s.instrumentation()
+	started := time.Now()
+
+	ctx, span := inst.StartSpan(ctx, "artisan.seeder")
+	span.SetAttribute("seeder.name", name)
+	var content []byte
+	defer func() {
+		inst.ObserveDuration(telemetry.SeederDuration, time.Since(started), nil)
+		if err != nil {
+			inst.IncCounter(telemetry.SeedersFailed, nil)
+		} else {
+			inst.IncCounter(telemetry.SeedersApplied, nil)
+		}
+		s.recordHistory(name, content, started, err)
+		span.End(err)
+	}()
 
 	// Read and parse SQL file
-	statements, err := s.parseSeederSQL(filePath)
+	content, err = os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to parse seeder %s: %w", name, err)
 	}
 
+	statements, err := s.parseSeederContent(string(content))
+	if err != nil {
+		return fmt.Errorf("failed to parse seeder %s: %w", name, err)
+	}
+
+	// A retry starts the transaction over, which is only safe when none of
+	// it has been committed already
+	ran := 0
+	retryable := func(err error) bool {
+		var stmtErr *statement.Error
+		failed := errors.As(err, &stmtErr)
+		return !errors.Is(err, ErrTimeout) && statement.IsTransient(s.Driver, err) &&
+			!statement.CommittedImplicitly(s.Driver, statements[:ran], failed)
+	}
+
+	attempts := 0
+	defer func() { span.SetAttribute("seeder.attempts", attempts) }()
+
+	return s.Retry.Do(ctx, func(attempt int) error {
+		attempts = attempt
+		ran = 0
+		return s.applySeeder(ctx, filePath, statements, record, &ran)
+	}, retryable, func(attempt int, err error, delay time.Duration) {
+		color.Yellow("⚠ Seeder %s failed on attempt %d/%d, retrying in %s: %v", name, attempt, s.Retry.MaxAttempts, delay, err)
+	})
+}
+
+// applySeeder runs the statements, and records the seeder when asked, in
+// one transaction. ran counts the statements that were started.
+func (s *Seeder) applySeeder(ctx context.Context, filePath string, statements []statement.Statement, record bool, ran *int) (err error) {
+	name := filepath.Base(filePath)
+	inst := s.instrumentation()
+
+	var timeouts, reset []string
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+		defer func() { err = s.timeoutError(ctx, err) }()
+		timeouts, reset = statement.Timeouts(s.Driver, s.Timeout, true)
+	}
+	reset = append(reset, statement.Resets(s.Driver, s.InitStatements)...)
+
+	// One connection, so timeouts and session settings can be reset afterwards
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect for seeder %s: %w", name, err)
+	}
+	defer conn.Close()
+	defer func() {
+		for _, query := range reset {
+			conn.ExecContext(context.Background(), query)
+		}
+	}()
+	if s.Timeout > 0 {
+		defer statement.KillOnDeadline(ctx, s.Driver, s.DB, conn)()
+	}
+
 	// Start transaction for atomic seeding
-	tx, err := s.DB.Begin()
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction for seeder %s: %w", name, err)
 	}
 
+	for _, query := range append(timeouts, s.InitStatements...) {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to initialize session for seeder %s: %s: %w", name, query, err)
+		}
+	}
+
 	// Execute each SQL statement within transaction
-	for _, stmt := range statements {
-		if stmt == "" {
-			continue
+	for i, stmt := range statements {
+		*ran = i + 1
+		_, stmtSpan := inst.StartSpan(ctx, "artisan.statement")
+		stmtSpan.SetAttribute("statement.index", stmt.Index)
+		stmtSpan.SetAttribute("statement.line", stmt.StartLine)
+		_, err := tx.ExecContext(ctx, stmt.SQL)
+		stmtSpan.End(err)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to run seeder %s: %w", name, statement.NewError(filePath, s.Driver, stmt, err))
 		}
-		if _, err := tx.Exec(stmt); err != nil {
+	}
+
+	// Record seeder within same transaction
+	if record {
+		if _, err := tx.ExecContext(ctx, s.recordQuery(), name); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to run seeder %s: %w", name, err)
+			return fmt.Errorf("failed to record seeder %s: %w", name, err)
 		}
 	}
 
@@ -143,11 +307,73 @@ func (s *Seeder) RunFile(filePath string) error {
 		return fmt.Errorf("failed to commit seeder %s: %w", name, err)
 	}
 
-	color.Green("✓ Seeded: %s", name)
 	return nil
 }
 
-func (s *Seeder) AutoSeed(seedersPath string) error {
+// timeoutError marks err as ErrTimeout when the deadline passed or the
+// server cancelled a statement for running too long.
+func (s *Seeder) timeoutError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var statementTimeout *statement.StatementTimeoutError
+	var lockTimeout *statement.LockTimeoutError
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.As(err, &statementTimeout) || errors.As(err, &lockTimeout) {
+		return fmt.Errorf("%w after %s: %w", ErrTimeout, s.Timeout, err)
+	}
+	return err
+}
+
+// recordHistory appends to the shared migration_history audit log. It runs
+// outside the seeder transaction so failed attempts are kept as well.
+func (s *Seeder) recordHistory(name string, content []byte, started time.Time, err error) {
+	entry := history.Entry{
+		Migration: name,
+		Direction: history.DirectionSeed,
+		Duration:  time.Since(started),
+		Outcome:   history.OutcomeSuccess,
+	}
+	if content != nil {
+		entry.Checksum = history.Checksum(content)
+	}
+	if err != nil {
+		entry.Outcome = history.OutcomeFailure
+		entry.Error = err.Error()
+	}
+
+	if err := history.Record(s.DB, s.Driver, entry); err != nil {
+		color.Yellow("⚠ Failed to write seeder history for %s: %v", name, err)
+	}
+}
+
+func (s *Seeder) instrumentation() telemetry.Instrumentation {
+	return telemetry.Or(s.Instrumentation)
+}
+
+// startCommand opens the top-level span for a public entry point and records
+// its duration once the returned function is called with the final error.
+func (s *Seeder) startCommand(command string) (context.Context, func(error)) {
+	inst := s.instrumentation()
+	started := time.Now()
+
+	ctx, span := inst.StartSpan(context.Background(), "artisan."+command)
+	span.SetAttribute("db.system", s.Driver)
+
+	return ctx, func(err error) {
+		status := "success"
+		if err != nil {
+			status = "failure"
+		}
+		inst.ObserveDuration(telemetry.CommandDuration, time.Since(started), map[string]string{"command": command, "status": status})
+		span.End(err)
+	}
+}
+
+func (s *Seeder) AutoSeed(seedersPath string) (err error) {
+	ctx, finish := s.startCommand("auto_seed")
+	defer func() { finish(err) }()
+
 	if err := s.EnsureSeedersTable(); err != nil {
 		return fmt.Errorf("failed to ensure seeders table: %w", err)
 	}
@@ -171,47 +397,8 @@ func (s *Seeder) AutoSeed(seedersPath string) error {
 			continue
 		}
 
-		// Read and parse SQL file
-		statements, err := s.parseSeederSQL(file)
-		if err != nil {
-			return fmt.Errorf("failed to parse seeder %s: %w", name, err)
-		}
-
-		// Start transaction for atomic seeding
-		tx, err := s.DB.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction for seeder %s: %w", name, err)
-		}
-
-		// Execute each SQL statement within transaction
-		for _, stmt := range statements {
-			if stmt == "" {
-				continue
-			}
-			if _, err := tx.Exec(stmt); err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to run seeder %s: %w", name, err)
-			}
-		}
-
-		// Record seeder within same transaction
-		var recordQuery string
-		if s.Driver == "postgres" {
-			recordQuery = "INSERT INTO seeders (seeder) VALUES ($1)"
-		} else if s.Driver == "sqlserver" || s.Driver == "mssql" {
-			recordQuery = "INSERT INTO seeders (seeder) VALUES (@p1)"
-		} else {
-			recordQuery = "INSERT INTO seeders (seeder) VALUES (?)"
-		}
-
-		if _, err := tx.Exec(recordQuery, name); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record seeder %s: %w", name, err)
-		}
-
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit seeder %s: %w", name, err)
+		if err := s.runSeeder(ctx, file, true); err != nil {
+			return err
 		}
 
 		executed++
@@ -224,7 +411,10 @@ func (s *Seeder) AutoSeed(seedersPath string) error {
 	return nil
 }
 
-func (s *Seeder) Run(seedersPath string) error {
+func (s *Seeder) Run(seedersPath string) (err error) {
+	ctx, finish := s.startCommand("seed")
+	defer func() { finish(err) }()
+
 	files, err := s.getSeederFiles(seedersPath)
 	if err != nil {
 		return fmt.Errorf("failed to get seeder files: %w", err)
@@ -233,32 +423,8 @@ func (s *Seeder) Run(seedersPath string) error {
 	for _, file := range files {
 		name := filepath.Base(file)
 
-		// Read and parse SQL file
-		statements, err := s.parseSeederSQL(file)
-		if err != nil {
-			return fmt.Errorf("failed to parse seeder %s: %w", name, err)
-		}
-
-		// Start transaction for atomic seeding
-		tx, err := s.DB.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction for seeder %s: %w", name, err)
-		}
-
-		// Execute each SQL statement within transaction
-		for _, stmt := range statements {
-			if stmt == "" {
-				continue
-			}
-			if _, err := tx.Exec(stmt); err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to run seeder %s: %w", name, err)
-			}
-		}
-
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit seeder %s: %w", name, err)
+		if err := s.runSeeder(ctx, file, false); err != nil {
+			return err
 		}
 
 		color.Green("✓ Seeded: %s", name)
@@ -267,7 +433,10 @@ func (s *Seeder) Run(seedersPath string) error {
 	return nil
 }
 
-func (s *Seeder) RunWithTracking(seedersPath string) error {
+func (s *Seeder) RunWithTracking(seedersPath string) (err error) {
+	ctx, finish := s.startCommand("seed")
+	defer func() { finish(err) }()
+
 	if err := s.EnsureSeedersTable(); err != nil {
 		return fmt.Errorf("failed to ensure seeders table: %w", err)
 	}
@@ -292,47 +461,8 @@ func (s *Seeder) RunWithTracking(seedersPath string) error {
 			continue
 		}
 
-		// Read and parse SQL file
-		statements, err := s.parseSeederSQL(file)
-		if err != nil {
-			return fmt.Errorf("failed to parse seeder %s: %w", name, err)
-		}
-
-		// Start transaction for atomic seeding
-		tx, err := s.DB.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction for seeder %s: %w", name, err)
-		}
-
-		// Execute each SQL statement within transaction
-		for _, stmt := range statements {
-			if stmt == "" {
-				continue
-			}
-			if _, err := tx.Exec(stmt); err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to run seeder %s: %w", name, err)
-			}
-		}
-
-		// Record seeder within same transaction
-		var recordQuery string
-		if s.Driver == "postgres" {
-			recordQuery = "INSERT INTO seeders (seeder) VALUES ($1)"
-		} else if s.Driver == "sqlserver" || s.Driver == "mssql" {
-			recordQuery = "INSERT INTO seeders (seeder) VALUES (@p1)"
-		} else {
-			recordQuery = "INSERT INTO seeders (seeder) VALUES (?)"
-		}
-
-		if _, err := tx.Exec(recordQuery, name); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record seeder %s: %w", name, err)
-		}
-
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit seeder %s: %w", name, err)
+		if err := s.runSeeder(ctx, file, true); err != nil {
+			return err
 		}
 
 		color.Green("✓ Seeded: %s", name)
@@ -376,9 +506,15 @@ type SeederStatus struct {
 	Seeded bool
 }
 
+// Status lists the seeder files and whether each has run. It only reads, so
+// it returns no statuses when the seeders table does not exist yet.
 func (s *Seeder) Status(seedersPath string) ([]SeederStatus, error) {
-	if err := s.EnsureSeedersTable(); err != nil {
-		return nil, fmt.Errorf("failed to ensure seeders table: %w", err)
+	exists, err := s.tableExists("seeders")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check seeders table: %w", err)
+	}
+	if !exists {
+		return nil, nil
 	}
 
 	seeded, err := s.getSeeded()
@@ -408,7 +544,10 @@ func (s *Seeder) MakeSeeder(seederName, seedersPath string) error {
 	filename := seederName
 	filepath := filepath.Join(seedersPath, filename)
 
-	template := s.getSeederTemplate(seederName)
+	template, err := s.getSeederTemplate(seederName)
+	if err != nil {
+		return err
+	}
 
 	if err := os.MkdirAll(seedersPath, 0755); err != nil {
 		return fmt.Errorf("failed to create seeders directory: %w", err)
@@ -422,52 +561,16 @@ func (s *Seeder) MakeSeeder(seederName, seedersPath string) error {
 	return nil
 }
 
-func (s *Seeder) getSeederTemplate(seederName string) string {
-	return fmt.Sprintf(`-- Seeder: %s
-
--- Add your INSERT statements here
--- Example:
--- INSERT INTO users (name, email, password) VALUES 
---   ('John Doe', 'john@example.com', 'hashed_password'),
---   ('Jane Smith', 'jane@example.com', 'hashed_password');
-
-`, seederName)
+func (s *Seeder) getSeederTemplate(seederName string) (string, error) {
+	table := strings.TrimSuffix(strings.TrimSuffix(seederName, ".sql"), "_seeder")
+	return stub.Render(s.StubsPath, stub.Seeder, stub.NewData(seederName, table, s.Driver))
 }
 
-func (s *Seeder) parseSeederSQL(filePath string) ([]string, error) {
-	content, err := os.ReadFile(filePath)
+func (s *Seeder) parseSeederContent(text string) ([]statement.Statement, error) {
+	text, err := expand.SQL(text, s.Driver, s.Variables)
 	if err != nil {
 		return nil, err
 	}
 
-	text := string(content)
-
-	// Remove comment lines starting with --
-	lines := strings.Split(text, "\n")
-	var sqlLines []string
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		// Skip empty lines and comment lines
-		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
-			continue
-		}
-		sqlLines = append(sqlLines, line)
-	}
-
-	sql := strings.Join(sqlLines, "\n")
-	sql = strings.TrimSpace(sql)
-
-	// Split by semicolon to get individual statements
-	statements := strings.Split(sql, ";")
-
-	// Trim each statement
-	var result []string
-	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt != "" {
-			result = append(result, stmt)
-		}
-	}
-
-	return result, nil
+	return statement.Split(text, s.Driver, 1), nil
 }
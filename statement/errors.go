@@ -0,0 +1,238 @@
+package statement
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Error is a statement that failed, with where it is in the file. Err is
+// one of the typed errors below when the driver error is recognised.
+type Error struct {
+	File      string
+	Statement Statement
+	Err       error
+}
+
+func (e *Error) Error() string {
+	lines := strconv.Itoa(e.Statement.StartLine)
+	if e.Statement.EndLine > e.Statement.StartLine {
+		lines += "-" + strconv.Itoa(e.Statement.EndLine)
+	}
+	return fmt.Sprintf("%s:%s: statement %d: %v\n  %s", e.File, lines, e.Statement.Index, e.Err, Snippet(e.Statement.SQL, 120))
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewError describes a failed statement, classifying the driver error.
+func NewError(file, driver string, stmt Statement, err error) *Error {
+	return &Error{File: file, Statement: stmt, Err: Classify(driver, err)}
+}
+
+// DriverError carries the driver's own error and code. The typed errors
+// below embed it, so errors.As works on both the kind and the details.
+type DriverError struct {
+	Code string
+	Err  error
+}
+
+func (e *DriverError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DriverError) Unwrap() error {
+	return e.Err
+}
+
+// DuplicateTableError is returned when a table or other object already exists.
+type DuplicateTableError struct{ DriverError }
+
+// SyntaxError is returned when the database cannot parse a statement.
+type SyntaxError struct{ DriverError }
+
+// LockTimeoutError is returned when a statement gave up waiting for a lock.
+type LockTimeoutError struct{ DriverError }
+
+// StatementTimeoutError is returned when the server cancelled a statement
+// that ran longer than its timeout.
+type StatementTimeoutError struct{ DriverError }
+
+// mysqlCode matches the "Error 1050 (42S01): ..." format of go-sql-driver.
+var mysqlCode = regexp.MustCompile(`^Error (\d+)`)
+
+// Classify wraps a driver error in DuplicateTableError, SyntaxError,
+// LockTimeoutError or StatementTimeoutError when its code is recognised, and returns it unchanged
+// otherwise. Drivers are matched by their error methods and messages, so
+// this package does not import any of them.
+func Classify(driver string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var code string
+	kind := ""
+
+	switch driver {
+	case "postgres":
+		var pgErr interface{ SQLState() string }
+		if errors.As(err, &pgErr) {
+			code = pgErr.SQLState()
+			switch code {
+			case "42P07", "42P06", "42710":
+				kind = "duplicate"
+			case "42601":
+				kind = "syntax"
+			case "55P03":
+				kind = "lock"
+			case "57014":
+				kind = "timeout"
+			}
+		}
+	case "sqlserver", "mssql":
+		var msErr interface{ SQLErrorNumber() int32 }
+		if errors.As(err, &msErr) {
+			code = strconv.Itoa(int(msErr.SQLErrorNumber()))
+			switch code {
+			case "2714":
+				kind = "duplicate"
+			case "102", "156":
+				kind = "syntax"
+			case "1222":
+				kind = "lock"
+			}
+		}
+	case "sqlite", "sqlite3":
+		message := err.Error()
+		switch {
+		case strings.Contains(message, "already exists"):
+			kind = "duplicate"
+		case strings.Contains(message, "syntax error"):
+			kind = "syntax"
+		case strings.Contains(message, "database is locked"), strings.Contains(message, "database table is locked"):
+			kind = "lock"
+		case strings.Contains(message, "interrupted"):
+			kind = "timeout"
+		}
+	default:
+		if match := mysqlCode.FindStringSubmatch(err.Error()); match != nil {
+			code = match[1]
+			switch code {
+			case "1050":
+				kind = "duplicate"
+			case "1064", "1149":
+				kind = "syntax"
+			case "1205":
+				kind = "lock"
+			case "3024":
+				kind = "timeout"
+			}
+		}
+	}
+
+	base := DriverError{Code: code, Err: err}
+	switch kind {
+	case "duplicate":
+		return &DuplicateTableError{base}
+	case "syntax":
+		return &SyntaxError{base}
+	case "lock":
+		return &LockTimeoutError{base}
+	case "timeout":
+		return &StatementTimeoutError{base}
+	}
+	return err
+}
+
+// Snippet shortens SQL to one line of at most maxLen characters.
+func Snippet(sql string, maxLen int) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if len(sql) > maxLen {
+		return sql[:maxLen] + "..."
+	}
+	return sql
+}
+
+// transientCodes are driver codes for failures that may succeed when the
+// transaction is run again: deadlocks, serialization failures, lock
+// timeouts and lost connections.
+var transientCodes = map[string]map[string]bool{
+	"mysql":     {"1205": true, "1213": true, "2006": true, "2013": true},
+	"postgres":  {"40001": true, "40P01": true, "55P03": true, "57P01": true},
+	"sqlserver": {"1205": true, "1222": true},
+}
+
+// IsTransient reports whether err is worth retrying with a new transaction.
+func IsTransient(driver string, err error) bool {
+	if err == nil {
+		return false
+	}
+	// A deadline is a net.Error too, but running out of time again won't help
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, sqldriver.ErrBadConn) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	message := err.Error()
+	switch driver {
+	case "postgres":
+		var pgErr interface{ SQLState() string }
+		if errors.As(err, &pgErr) {
+			// Class 08 is connection exceptions
+			return transientCodes["postgres"][pgErr.SQLState()] || strings.HasPrefix(pgErr.SQLState(), "08")
+		}
+	case "sqlserver", "mssql":
+		var msErr interface{ SQLErrorNumber() int32 }
+		if errors.As(err, &msErr) {
+			return transientCodes["sqlserver"][strconv.Itoa(int(msErr.SQLErrorNumber()))]
+		}
+	case "sqlite", "sqlite3":
+		return strings.Contains(message, "database is locked") || strings.Contains(message, "database table is locked")
+	default:
+		// The code is at the start of the driver's own message
+		for e := err; e != nil; e = errors.Unwrap(e) {
+			if match := mysqlCode.FindStringSubmatch(e.Error()); match != nil {
+				return transientCodes["mysql"][match[1]]
+			}
+		}
+		return strings.Contains(message, "invalid connection")
+	}
+	return false
+}
+
+// implicitCommit matches MySQL statements that commit the open transaction
+// before they run and again when they finish.
+var implicitCommit = regexp.MustCompile(`(?i)^\s*(CREATE|ALTER|DROP|RENAME|TRUNCATE)\b`)
+
+// CommittedImplicitly reports whether running the statements in ran, in a
+// transaction, has committed part of it, as DDL does on MySQL. ran holds the
+// statements that were started; failed says the last of them failed. A DDL
+// statement that fails as the very first statement has committed nothing,
+// so running the transaction again is safe.
+func CommittedImplicitly(driver string, ran []Statement, failed bool) bool {
+	if driver != "mysql" && driver != "" {
+		return false
+	}
+	for i, stmt := range ran {
+		if !implicitCommit.MatchString(stmt.SQL) {
+			continue
+		}
+		if i == 0 && failed && len(ran) == 1 {
+			return false
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package statement
+
+import (
+	"strings"
+)
+
+// Resets returns the statements that undo the SET statements in queries, so
+// a pooled connection does not carry one migration's settings into the next.
+// SQL Server resets sessions itself when a pooled connection is reused, and
+// SQLite has no SET.
+func Resets(driver string, queries []string) []string {
+	var reset []string
+
+	switch driver {
+	case "postgres":
+		if len(queries) > 0 {
+			reset = []string{"RESET ALL", "RESET ROLE"}
+		}
+	case "sqlserver", "mssql", "sqlite", "sqlite3":
+	default:
+		for _, query := range queries {
+			reset = append(reset, mysqlResets(query)...)
+		}
+	}
+	return reset
+}
+
+// mysqlResets restores the session variables a MySQL SET assigns.
+func mysqlResets(query string) []string {
+	fields := strings.Fields(query)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "SET") {
+		return nil
+	}
+	if strings.EqualFold(fields[1], "ROLE") {
+		return []string{"SET ROLE DEFAULT"}
+	}
+
+	var reset []string
+	body := strings.TrimSpace(query[len(fields[0]):])
+	for _, assignment := range strings.Split(body, ",") {
+		name, _, ok := strings.Cut(assignment, "=")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if upper := strings.ToUpper(name); strings.HasPrefix(upper, "SESSION ") {
+			name = strings.TrimSpace(name[len("SESSION "):])
+		}
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "@@session."), "@@")
+		// User variables (@x) and multi-word forms are left alone
+		if name == "" || strings.HasPrefix(name, "@") || strings.ContainsAny(name, " \t") {
+			continue
+		}
+		reset = append(reset, "SET SESSION "+name+" = DEFAULT")
+	}
+	return reset
+}
@@ -0,0 +1,247 @@
+// Package statement splits SQL files into statements that remember where
+// they came from, and describes statement failures with that position.
+package statement
+
+import (
+	"strings"
+)
+
+// Statement is one SQL statement and its position in the source file.
+type Statement struct {
+	SQL string
+
+	// Index is the 1-based position among the file's statements.
+	Index int
+
+	// StartLine and EndLine are the 1-based file lines the SQL spans.
+	StartLine int
+	EndLine   int
+}
+
+// Split breaks text into statements at semicolons that are outside quotes,
+// comments and, for PostgreSQL, dollar-quoted bodies. firstLine is the file
+// line that text starts on. Leading comments are dropped, so a statement
+// that follows a comment is kept, and comment-only statements are skipped.
+//
+// MySQL files may change the terminator with a DELIMITER line, as the mysql
+// client does, and SQL Server files may end a batch with a GO line.
+func Split(text, driver string, firstLine int) []Statement {
+	mysql := driver == "mysql" || driver == ""
+	s := splitter{
+		text:           text,
+		line:           firstLine,
+		delimiter:      ";",
+		backslash:      mysql,
+		dollar:         driver == "postgres",
+		nested:         driver == "postgres",
+		brackets:       driver == "sqlserver" || driver == "mssql",
+		delimiterLines: mysql,
+		goLines:        driver == "sqlserver" || driver == "mssql",
+	}
+	s.run()
+	return s.statements
+}
+
+type splitter struct {
+	text      string
+	pos       int
+	line      int
+	delimiter string
+	backslash bool // MySQL escapes quotes with a backslash
+	dollar    bool // PostgreSQL $tag$ ... $tag$ bodies
+	nested    bool // PostgreSQL block comments nest
+	brackets  bool // SQL Server [identifiers]
+
+	delimiterLines bool // MySQL DELIMITER lines
+	goLines        bool // SQL Server GO batch separators
+
+	current    strings.Builder
+	started    bool
+	startLine  int
+	endLine    int
+	statements []Statement
+}
+
+func (s *splitter) run() {
+	for s.pos < len(s.text) {
+		c := s.text[s.pos]
+
+		switch {
+		case s.delimiterLines && !s.started && s.directive("DELIMITER"):
+			s.flush()
+			s.delimiter = strings.TrimSpace(s.skipLine()[len("DELIMITER"):])
+			if s.delimiter == "" {
+				s.delimiter = ";"
+			}
+		case s.goLines && s.directive("GO"):
+			// GO may be followed by a repeat count, which is ignored
+			s.flush()
+			s.skipLine()
+		case strings.HasPrefix(s.text[s.pos:], s.delimiter):
+			s.flush()
+			s.pos += len(s.delimiter)
+		case c == '\n':
+			s.line++
+			s.whitespace(c)
+		case c == ' ' || c == '\t' || c == '\r':
+			s.whitespace(c)
+		case strings.HasPrefix(s.text[s.pos:], "--"):
+			s.lineComment()
+		case strings.HasPrefix(s.text[s.pos:], "/*"):
+			s.blockComment()
+		case c == '\'' || c == '"' || c == '`':
+			s.quoted(c, c)
+		case c == '[' && s.brackets:
+			s.quoted(c, ']')
+		case c == '$' && s.dollar:
+			s.dollarQuoted()
+		default:
+			s.code(s.text[s.pos : s.pos+1])
+			s.pos++
+		}
+	}
+	s.flush()
+}
+
+// directive reports whether the current line is the given client command,
+// alone or followed by arguments, e.g. "GO" or "DELIMITER //".
+func (s *splitter) directive(word string) bool {
+	rest := s.text[s.pos:]
+	if len(rest) < len(word) || !strings.EqualFold(rest[:len(word)], word) {
+		return false
+	}
+	if len(rest) > len(word) && !strings.ContainsRune(" \t\r\n", rune(rest[len(word)])) {
+		return false
+	}
+	start := strings.LastIndexByte(s.text[:s.pos], '\n') + 1
+	return strings.TrimSpace(s.text[start:s.pos]) == ""
+}
+
+// skipLine moves past the rest of the current line, leaving the newline, and
+// returns what it skipped.
+func (s *splitter) skipLine() string {
+	end := strings.IndexByte(s.text[s.pos:], '\n')
+	if end == -1 {
+		end = len(s.text) - s.pos
+	}
+	line := s.text[s.pos : s.pos+end]
+	s.pos += end
+	return line
+}
+
+func (s *splitter) whitespace(c byte) {
+	if s.started {
+		s.current.WriteByte(c)
+	}
+	s.pos++
+}
+
+// code appends SQL text, starting a statement on its first line.
+func (s *splitter) code(text string) {
+	if !s.started {
+		s.started = true
+		s.startLine = s.line
+	}
+	s.current.WriteString(text)
+	s.line += strings.Count(text, "\n")
+	s.endLine = s.line
+}
+
+func (s *splitter) lineComment() {
+	end := strings.IndexByte(s.text[s.pos:], '\n')
+	if end == -1 {
+		end = len(s.text) - s.pos
+	}
+	if s.started {
+		s.current.WriteString(s.text[s.pos : s.pos+end])
+	}
+	s.pos += end
+}
+
+func (s *splitter) blockComment() {
+	end := len(s.text)
+	depth := 0
+	for i := s.pos; i+1 < len(s.text); i++ {
+		if s.text[i] == '/' && s.text[i+1] == '*' && (depth == 0 || s.nested) {
+			depth++
+			i++
+		} else if s.text[i] == '*' && s.text[i+1] == '/' {
+			depth--
+			i++
+			if depth == 0 {
+				end = i + 1
+				break
+			}
+		}
+	}
+	comment := s.text[s.pos:end]
+	s.pos = end
+
+	// MySQL /*! ... */ and optimizer /*+ ... */ comments are executed
+	if s.started || strings.HasPrefix(comment, "/*!") || strings.HasPrefix(comment, "/*+") {
+		s.code(comment)
+		return
+	}
+	s.line += strings.Count(comment, "\n")
+}
+
+func (s *splitter) quoted(open, closing byte) {
+	end := s.pos + 1
+	for end < len(s.text) {
+		if s.backslash && s.text[end] == '\\' && open != '`' {
+			end += 2
+			continue
+		}
+		if s.text[end] == closing {
+			break
+		}
+		end++
+	}
+	if end >= len(s.text) {
+		end = len(s.text) - 1
+	}
+	s.code(s.text[s.pos : end+1])
+	s.pos = end + 1
+}
+
+func (s *splitter) dollarQuoted() {
+	// $tag$ where tag is empty or an identifier
+	closing := strings.IndexByte(s.text[s.pos+1:], '$')
+	if closing == -1 || !isTag(s.text[s.pos+1:s.pos+1+closing]) {
+		s.code("$")
+		s.pos++
+		return
+	}
+	tag := s.text[s.pos : s.pos+closing+2]
+
+	end := strings.Index(s.text[s.pos+len(tag):], tag)
+	if end == -1 {
+		end = len(s.text)
+	} else {
+		end += s.pos + 2*len(tag)
+	}
+	s.code(s.text[s.pos:end])
+	s.pos = end
+}
+
+func isTag(tag string) bool {
+	for i, r := range tag {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *splitter) flush() {
+	if s.started {
+		s.statements = append(s.statements, Statement{
+			SQL:       strings.TrimSpace(s.current.String()),
+			Index:     len(s.statements) + 1,
+			StartLine: s.startLine,
+			EndLine:   s.endLine,
+		})
+	}
+	s.current.Reset()
+	s.started = false
+}
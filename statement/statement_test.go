@@ -0,0 +1,277 @@
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		text   string
+		want   []Statement
+	}{
+		{
+			name:   "semicolons and lines",
+			driver: "sqlite",
+			text:   "CREATE TABLE a (id INT);\n\nCREATE TABLE b (\n  id INT\n);",
+			want: []Statement{
+				{SQL: "CREATE TABLE a (id INT)", Index: 1, StartLine: 1, EndLine: 1},
+				{SQL: "CREATE TABLE b (\n  id INT\n)", Index: 2, StartLine: 3, EndLine: 5},
+			},
+		},
+		{
+			name:   "missing final semicolon",
+			driver: "sqlite",
+			text:   "SELECT 1;\nSELECT 2\n",
+			want: []Statement{
+				{SQL: "SELECT 1", Index: 1, StartLine: 1, EndLine: 1},
+				{SQL: "SELECT 2", Index: 2, StartLine: 2, EndLine: 2},
+			},
+		},
+		{
+			name:   "leading and comment-only statements",
+			driver: "sqlite",
+			text:   "-- first\n/* block\ncomment */\nSELECT 1;\n-- nothing here\n;",
+			want: []Statement{
+				{SQL: "SELECT 1", Index: 1, StartLine: 4, EndLine: 4},
+			},
+		},
+		{
+			name:   "semicolons in quotes and comments",
+			driver: "sqlite",
+			text:   "INSERT INTO t VALUES ('a;b', \"c;d\"); -- e;f\nSELECT /* g;h */ 1;",
+			want: []Statement{
+				{SQL: "INSERT INTO t VALUES ('a;b', \"c;d\")", Index: 1, StartLine: 1, EndLine: 1},
+				{SQL: "SELECT /* g;h */ 1", Index: 2, StartLine: 2, EndLine: 2},
+			},
+		},
+		{
+			name:   "doubled quotes",
+			driver: "postgres",
+			text:   "SELECT 'it''s; fine';\nSELECT 2;",
+			want: []Statement{
+				{SQL: "SELECT 'it''s; fine'", Index: 1, StartLine: 1, EndLine: 1},
+				{SQL: "SELECT 2", Index: 2, StartLine: 2, EndLine: 2},
+			},
+		},
+		{
+			name:   "mysql backslash escapes and backticks",
+			driver: "mysql",
+			text:   "INSERT INTO `t;1` VALUES ('a\\';b');\nSELECT 2;",
+			want: []Statement{
+				{SQL: "INSERT INTO `t;1` VALUES ('a\\';b')", Index: 1, StartLine: 1, EndLine: 1},
+				{SQL: "SELECT 2", Index: 2, StartLine: 2, EndLine: 2},
+			},
+		},
+		{
+			name:   "backslash is literal outside mysql",
+			driver: "postgres",
+			text:   "SELECT 'a\\';\nSELECT 2;",
+			want: []Statement{
+				{SQL: "SELECT 'a\\'", Index: 1, StartLine: 1, EndLine: 1},
+				{SQL: "SELECT 2", Index: 2, StartLine: 2, EndLine: 2},
+			},
+		},
+		{
+			name:   "mysql executable comments",
+			driver: "mysql",
+			text:   "/*!40101 SET NAMES utf8mb4 */;\nSELECT /*+ MAX_EXECUTION_TIME(1) */ 1;",
+			want: []Statement{
+				{SQL: "/*!40101 SET NAMES utf8mb4 */", Index: 1, StartLine: 1, EndLine: 1},
+				{SQL: "SELECT /*+ MAX_EXECUTION_TIME(1) */ 1", Index: 2, StartLine: 2, EndLine: 2},
+			},
+		},
+		{
+			name:   "mysql delimiter",
+			driver: "mysql",
+			text: "DELIMITER //\n" +
+				"CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW\nBEGIN\n  SET NEW.x = 1;\nEND//\n" +
+				"DELIMITER ;\n" +
+				"SELECT 1;",
+			want: []Statement{
+				{SQL: "CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW\nBEGIN\n  SET NEW.x = 1;\nEND", Index: 1, StartLine: 2, EndLine: 5},
+				{SQL: "SELECT 1", Index: 2, StartLine: 7, EndLine: 7},
+			},
+		},
+		{
+			name:   "delimiter column is not a directive",
+			driver: "mysql",
+			text:   "CREATE TABLE t (\ndelimiter VARCHAR(1)\n);",
+			want: []Statement{
+				{SQL: "CREATE TABLE t (\ndelimiter VARCHAR(1)\n)", Index: 1, StartLine: 1, EndLine: 3},
+			},
+		},
+		{
+			name:   "postgres dollar quoting",
+			driver: "postgres",
+			text: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\n" +
+				"DO $body$ BEGIN PERFORM 'x;'; END $body$;\n" +
+				"SELECT $1;",
+			want: []Statement{
+				{SQL: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql", Index: 1, StartLine: 1, EndLine: 1},
+				{SQL: "DO $body$ BEGIN PERFORM 'x;'; END $body$", Index: 2, StartLine: 2, EndLine: 2},
+				{SQL: "SELECT $1", Index: 3, StartLine: 3, EndLine: 3},
+			},
+		},
+		{
+			name:   "dollar is not a quote outside postgres",
+			driver: "sqlite",
+			text:   "SELECT '$$';\nSELECT $$;",
+			want: []Statement{
+				{SQL: "SELECT '$$'", Index: 1, StartLine: 1, EndLine: 1},
+				{SQL: "SELECT $$", Index: 2, StartLine: 2, EndLine: 2},
+			},
+		},
+		{
+			name:   "postgres nested block comments",
+			driver: "postgres",
+			text:   "/* outer /* inner; */ still; */\nSELECT 1;",
+			want: []Statement{
+				{SQL: "SELECT 1", Index: 1, StartLine: 2, EndLine: 2},
+			},
+		},
+		{
+			name:   "block comments do not nest outside postgres",
+			driver: "sqlite",
+			text:   "/* a /* b */ SELECT 1;",
+			want: []Statement{
+				{SQL: "SELECT 1", Index: 1, StartLine: 1, EndLine: 1},
+			},
+		},
+		{
+			name:   "sqlserver brackets and go batches",
+			driver: "sqlserver",
+			text:   "CREATE TABLE [a;b] (id INT)\nGO\nCREATE PROCEDURE p AS\nSELECT 1;\nSELECT 2;\ngo\nSELECT 3",
+			want: []Statement{
+				{SQL: "CREATE TABLE [a;b] (id INT)", Index: 1, StartLine: 1, EndLine: 1},
+				{SQL: "CREATE PROCEDURE p AS\nSELECT 1", Index: 2, StartLine: 3, EndLine: 4},
+				{SQL: "SELECT 2", Index: 3, StartLine: 5, EndLine: 5},
+				{SQL: "SELECT 3", Index: 4, StartLine: 7, EndLine: 7},
+			},
+		},
+		{
+			name:   "go is only a separator for sqlserver",
+			driver: "postgres",
+			text:   "SELECT 1\nGO\n;",
+			want: []Statement{
+				{SQL: "SELECT 1\nGO", Index: 1, StartLine: 1, EndLine: 2},
+			},
+		},
+		{
+			name:   "unterminated quote runs to the end",
+			driver: "sqlite",
+			text:   "SELECT 'a;\nb",
+			want: []Statement{
+				{SQL: "SELECT 'a;\nb", Index: 1, StartLine: 1, EndLine: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(tt.text, tt.driver, 1)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitFirstLine(t *testing.T) {
+	got := Split("\nSELECT 1;\nSELECT\n2;", "mysql", 10)
+	want := []Statement{
+		{SQL: "SELECT 1", Index: 1, StartLine: 11, EndLine: 11},
+		{SQL: "SELECT\n2", Index: 2, StartLine: 12, EndLine: 13},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Split() = %#v, want %#v", got, want)
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	stmts := Split("CREATE TABLE a (id INT);\n\nINSERT INTO a\nVALUES (1);", "sqlite", 4)
+
+	tests := []struct {
+		stmt Statement
+		want string
+	}{
+		{stmts[0], "m.sql:4: statement 1: boom\n  CREATE TABLE a (id INT)"},
+		{stmts[1], "m.sql:6-7: statement 2: boom\n  INSERT INTO a VALUES (1)"},
+	}
+	for _, tt := range tests {
+		err := NewError("m.sql", "sqlite", tt.stmt, errors.New("boom"))
+		if err.Error() != tt.want {
+			t.Errorf("Error() = %q, want %q", err.Error(), tt.want)
+		}
+	}
+}
+
+type pgError struct{ code string }
+
+func (e pgError) Error() string    { return "pq: " + e.code }
+func (e pgError) SQLState() string { return e.code }
+
+type msError struct{ number int32 }
+
+func (e msError) Error() string         { return fmt.Sprintf("mssql: %d", e.number) }
+func (e msError) SQLErrorNumber() int32 { return e.number }
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		driver string
+		err    error
+		check  func(error) bool
+	}{
+		{"mysql", errors.New("Error 1050 (42S01): Table 'a' already exists"), func(err error) bool { var e *DuplicateTableError; return errors.As(err, &e) && e.Code == "1050" }},
+		{"mysql", errors.New("Error 1064 (42000): You have an error in your SQL syntax"), func(err error) bool { var e *SyntaxError; return errors.As(err, &e) }},
+		{"mysql", errors.New("Error 1205 (HY000): Lock wait timeout exceeded"), func(err error) bool { var e *LockTimeoutError; return errors.As(err, &e) }},
+		{"postgres", pgError{"42P07"}, func(err error) bool { var e *DuplicateTableError; return errors.As(err, &e) && e.Code == "42P07" }},
+		{"postgres", pgError{"57014"}, func(err error) bool { var e *StatementTimeoutError; return errors.As(err, &e) }},
+		{"sqlserver", msError{2714}, func(err error) bool { var e *DuplicateTableError; return errors.As(err, &e) && e.Code == "2714" }},
+		{"sqlite", errors.New("near \"CREAT\": syntax error"), func(err error) bool { var e *SyntaxError; return errors.As(err, &e) }},
+		{"sqlite", errors.New("no such table: a"), func(err error) bool { var e *DriverError; return !errors.As(err, &e) }},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(tt.driver, tt.err); !tt.check(got) {
+			t.Errorf("Classify(%s, %v) = %#v", tt.driver, tt.err, got)
+		}
+	}
+}
+
+func TestCommittedImplicitly(t *testing.T) {
+	stmts := func(sql ...string) []Statement {
+		var list []Statement
+		for i, s := range sql {
+			list = append(list, Statement{SQL: s, Index: i + 1})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name   string
+		driver string
+		ran    []Statement
+		failed bool
+		want   bool
+	}{
+		{"first DDL failed", "mysql", stmts("ALTER TABLE a ADD x INT"), true, false},
+		{"DDL succeeded before failure", "mysql", stmts("CREATE TABLE a (id INT)", "INSERT INTO a VALUES (1)"), true, true},
+		{"DML then failing DDL", "mysql", stmts("INSERT INTO a VALUES (1)", "ALTER TABLE a ADD x INT"), true, true},
+		{"only DML", "mysql", stmts("INSERT INTO a VALUES (1)", "UPDATE a SET id = 2"), true, false},
+		{"lower case DDL", "mysql", stmts("drop table a", "select 1"), true, true},
+		{"postgres DDL is transactional", "postgres", stmts("CREATE TABLE a (id INT)", "INSERT INTO a VALUES (1)"), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CommittedImplicitly(tt.driver, tt.ran, tt.failed); got != tt.want {
+				t.Errorf("CommittedImplicitly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
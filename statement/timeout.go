@@ -0,0 +1,83 @@
+package statement
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Timeouts returns the statements that make the server give up after
+// timeout, and the ones that restore its defaults afterwards. PostgreSQL
+// settings inside a transaction end with it, so reset is empty there.
+// SQLite has no server-side timeout and relies on the context deadline.
+func Timeouts(driver string, timeout time.Duration, inTransaction bool) (set, reset []string) {
+	ms := timeout.Milliseconds()
+	seconds := (ms + 999) / 1000
+
+	switch driver {
+	case "postgres":
+		scope := "SET "
+		if inTransaction {
+			scope = "SET LOCAL "
+		}
+		set = []string{
+			fmt.Sprintf("%sstatement_timeout = %d", scope, ms),
+			fmt.Sprintf("%slock_timeout = %d", scope, ms),
+		}
+		if !inTransaction {
+			reset = []string{"RESET statement_timeout", "RESET lock_timeout"}
+		}
+	case "sqlserver", "mssql":
+		set = []string{fmt.Sprintf("SET LOCK_TIMEOUT %d", ms)}
+		reset = []string{"SET LOCK_TIMEOUT -1"}
+	case "sqlite", "sqlite3":
+	default:
+		// max_execution_time only limits SELECT; a running ALTER TABLE is
+		// stopped by KillOnDeadline instead
+		set = []string{
+			fmt.Sprintf("SET SESSION max_execution_time = %d", ms),
+			fmt.Sprintf("SET SESSION lock_wait_timeout = %d", seconds),
+			fmt.Sprintf("SET SESSION innodb_lock_wait_timeout = %d", seconds),
+		}
+		reset = []string{
+			"SET SESSION max_execution_time = DEFAULT",
+			"SET SESSION lock_wait_timeout = DEFAULT",
+			"SET SESSION innodb_lock_wait_timeout = DEFAULT",
+		}
+	}
+	return set, reset
+}
+
+// KillOnDeadline makes MySQL stop the statement running on conn once ctx's
+// deadline passes. The driver only closes its socket, and the server would
+// otherwise keep running e.g. an ALTER TABLE and holding its locks, so a
+// KILL QUERY is sent over another connection from db. Call the returned
+// function before conn is reused; it waits for a KILL that is in flight.
+func KillOnDeadline(ctx context.Context, driver string, db *sql.DB, conn *sql.Conn) func() {
+	if driver != "mysql" && driver != "" {
+		return func() {}
+	}
+
+	var id int64
+	if err := conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(done)
+		if ctx.Err() != context.DeadlineExceeded {
+			return
+		}
+		killCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		db.ExecContext(killCtx, fmt.Sprintf("KILL QUERY %d", id))
+	})
+
+	return func() {
+		if !stop() {
+			<-done
+		}
+	}
+}
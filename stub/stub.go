@@ -0,0 +1,136 @@
+package stub
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hymns/go-artisan/expand"
+	"github.com/hymns/go-artisan/history"
+)
+
+// DefaultPath is where stub:publish writes the stubs and where the make
+// commands look for them.
+const DefaultPath = "./database/stubs"
+
+const (
+	Migration = "migration.stub"
+	Seeder    = "seeder.stub"
+)
+
+// Data is what a stub is rendered with. Action, Up and Down come from the
+// migration name (create, add, drop, ...) and are empty for seeders.
+type Data struct {
+	Name      string
+	Table     string
+	Action    string
+	Driver    string
+	Timestamp string
+	User      string
+	Up        string
+	Down      string
+}
+
+var defaults = map[string]string{
+	Migration: `-- Migration: {{.Name}}
+-- Created at: {{.Timestamp}}
+-- Database: {{.Driver}}
+
+--UP--
+{{.Up}}
+
+--DOWN--
+{{.Down}}
+`,
+	Seeder: `-- Seeder: {{.Name}}
+
+-- Add your INSERT statements here
+-- Example:
+-- INSERT INTO users (name, email, password) VALUES 
+--   ('John Doe', 'john@example.com', 'hashed_password'),
+--   ('Jane Smith', 'jane@example.com', 'hashed_password');
+
+`,
+}
+
+var funcs = template.FuncMap{
+	"env":   os.Getenv,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+func init() {
+	// SQL macros such as {{id}} are kept in the generated file and expanded
+	// when the migration runs.
+	for _, name := range expand.Macros() {
+		macro := "{{" + name + "}}"
+		funcs[name] = func() string { return macro }
+	}
+}
+
+// NewData fills in the timestamp and current user.
+func NewData(name, table, driver string) Data {
+	return Data{
+		Name:      name,
+		Table:     table,
+		Driver:    driver,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		User:      history.Username(),
+	}
+}
+
+// Publish writes the default stubs into dir. Existing stubs are kept unless
+// force is set. It returns the files that were written.
+func Publish(dir string, force bool) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create stubs directory: %w", err)
+	}
+
+	var written []string
+	for _, name := range []string{Migration, Seeder} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil && !force {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(defaults[name]), 0644); err != nil {
+			return written, fmt.Errorf("failed to write stub %s: %w", name, err)
+		}
+		written = append(written, path)
+	}
+
+	return written, nil
+}
+
+// Render executes the named stub from dir, falling back to the built-in
+// default when dir is empty or has no such stub.
+func Render(dir, name string, data Data) (string, error) {
+	text, ok := defaults[name]
+	if !ok {
+		return "", fmt.Errorf("unknown stub: %s", name)
+	}
+
+	if dir != "" {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			text = string(content)
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read stub %s: %w", name, err)
+		}
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse stub %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render stub %s: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,158 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the histogram upper bounds in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// Registry is a dependency-free Instrumentation that keeps counters and
+// histograms in memory and serves them in the Prometheus text format.
+// Spans are ignored; combine it with a tracer adapter to get both.
+type Registry struct {
+	mu         sync.Mutex
+	counters   map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+	labels     map[string]map[string]map[string]string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		counters:   make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*histogram),
+		labels:     make(map[string]map[string]map[string]string),
+	}
+}
+
+func (r *Registry) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+func (r *Registry) IncCounter(name string, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := r.remember(name, labels)
+	if r.counters[name] == nil {
+		r.counters[name] = make(map[string]float64)
+	}
+	r.counters[name][key]++
+}
+
+func (r *Registry) ObserveDuration(name string, d time.Duration, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := r.remember(name, labels)
+	if r.histograms[name] == nil {
+		r.histograms[name] = make(map[string]*histogram)
+	}
+	h := r.histograms[name][key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(DefaultBuckets))}
+		r.histograms[name][key] = h
+	}
+
+	seconds := d.Seconds()
+	for i, bound := range DefaultBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (r *Registry) remember(name string, labels map[string]string) string {
+	key := formatLabels(labels, "", "")
+	if r.labels[name] == nil {
+		r.labels[name] = make(map[string]map[string]string)
+	}
+	if _, ok := r.labels[name][key]; !ok {
+		copied := make(map[string]string, len(labels))
+		for k, v := range labels {
+			copied[k] = v
+		}
+		r.labels[name][key] = copied
+	}
+	return key
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+
+	for _, name := range sortedKeys(r.counters) {
+		fmt.Fprintf(&b, "# TYPE %s counter\n", name)
+		series := r.counters[name]
+		for _, key := range sortedKeys(series) {
+			fmt.Fprintf(&b, "%s%s %g\n", name, key, series[key])
+		}
+	}
+
+	for _, name := range sortedKeys(r.histograms) {
+		fmt.Fprintf(&b, "# TYPE %s histogram\n", name)
+		series := r.histograms[name]
+		for _, key := range sortedKeys(series) {
+			h := series[key]
+			labels := r.labels[name][key]
+			for i, bound := range DefaultBuckets {
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, formatLabels(labels, "le", fmt.Sprintf("%g", bound)), h.counts[i])
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, formatLabels(labels, "le", "+Inf"), h.count)
+			fmt.Fprintf(&b, "%s_sum%s %g\n", name, key, h.sum)
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, key, h.count)
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP exposes the registry so it can be mounted at /metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+func formatLabels(labels map[string]string, extraKey, extraValue string) string {
+	if len(labels) == 0 && extraKey == "" {
+		return ""
+	}
+
+	var parts []string
+	for _, k := range sortedKeys(labels) {
+		parts = append(parts, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	if extraKey != "" {
+		parts = append(parts, fmt.Sprintf("%s=%q", extraKey, extraValue))
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+)
+
+// Metric names reported by the migration and seeder runners.
+const (
+	MigrationsApplied = "artisan_migrations_applied_total"
+	MigrationsFailed  = "artisan_migrations_failed_total"
+	MigrationDuration = "artisan_migration_duration_seconds"
+	LockWait          = "artisan_migration_lock_wait_seconds"
+	CommandDuration   = "artisan_command_duration_seconds"
+	SeedersApplied    = "artisan_seeders_applied_total"
+	SeedersFailed     = "artisan_seeders_failed_total"
+	SeederDuration    = "artisan_seeder_duration_seconds"
+)
+
+// Span is a single traced operation. End must be called exactly once.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+// Instrumentation receives traces and metrics from migrations and seeders.
+// Adapters for OpenTelemetry, Prometheus or any other backend implement this
+// interface so the core packages never import those libraries directly.
+type Instrumentation interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+	IncCounter(name string, labels map[string]string)
+	ObserveDuration(name string, d time.Duration, labels map[string]string)
+}
+
+// Nop discards everything. It is used when no instrumentation is configured.
+var Nop Instrumentation = nop{}
+
+type nop struct{}
+
+func (nop) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+func (nop) IncCounter(name string, labels map[string]string) {}
+
+func (nop) ObserveDuration(name string, d time.Duration, labels map[string]string) {}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(key string, value interface{}) {}
+
+func (nopSpan) End(err error) {}
+
+// Or returns inst, or Nop when inst is nil.
+func Or(inst Instrumentation) Instrumentation {
+	if inst == nil {
+		return Nop
+	}
+	return inst
+}
+
+// Combine fans out to several instrumentations, e.g. an OpenTelemetry tracer
+// adapter together with a Registry for metrics.
+func Combine(insts ...Instrumentation) Instrumentation {
+	var list multi
+	for _, inst := range insts {
+		if inst != nil {
+			list = append(list, inst)
+		}
+	}
+	return list
+}
+
+type multi []Instrumentation
+
+func (m multi) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	spans := make(multiSpan, 0, len(m))
+	for _, inst := range m {
+		var span Span
+		ctx, span = inst.StartSpan(ctx, name)
+		spans = append(spans, span)
+	}
+	return ctx, spans
+}
+
+func (m multi) IncCounter(name string, labels map[string]string) {
+	for _, inst := range m {
+		inst.IncCounter(name, labels)
+	}
+}
+
+func (m multi) ObserveDuration(name string, d time.Duration, labels map[string]string) {
+	for _, inst := range m {
+		inst.ObserveDuration(name, d, labels)
+	}
+}
+
+type multiSpan []Span
+
+func (s multiSpan) SetAttribute(key string, value interface{}) {
+	for _, span := range s {
+		span.SetAttribute(key, value)
+	}
+}
+
+func (s multiSpan) End(err error) {
+	for i := len(s) - 1; i >= 0; i-- {
+		s[i].End(err)
+	}
+}
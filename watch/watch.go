@@ -0,0 +1,218 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hymns/go-artisan/migration"
+	"github.com/hymns/go-artisan/seeder"
+)
+
+// Watcher polls the migrations and seeders directories during local
+// development. New migrations and edited pending ones are applied, edits to
+// the most recently applied migration roll it back and re-apply it, and
+// changed seeders are re-run.
+type Watcher struct {
+	Migration      *migration.Migration
+	Seeder         *seeder.Seeder
+	MigrationsPath string
+	SeedersPath    string
+	Interval       time.Duration
+
+	// Production disables the automatic rollback of edited migrations.
+	Production bool
+}
+
+type snapshot map[string][]byte
+
+func New(m *migration.Migration, s *seeder.Seeder, migrationsPath, seedersPath string) *Watcher {
+	return &Watcher{
+		Migration:      m,
+		Seeder:         s,
+		MigrationsPath: migrationsPath,
+		SeedersPath:    seedersPath,
+		Interval:       time.Second,
+	}
+}
+
+// Run applies anything pending once, then watches until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	if err := w.Migration.Migrate(w.MigrationsPath); err != nil {
+		color.Red("✗ Migration failed: %v", err)
+	}
+	if w.Seeder != nil {
+		if err := w.Seeder.RunWithTracking(w.SeedersPath); err != nil {
+			color.Red("✗ Seeding failed: %v", err)
+		}
+	}
+
+	migrations := readDir(w.MigrationsPath)
+	seeders := readDir(w.SeedersPath)
+
+	color.Cyan("\nWatching %s and %s for changes...", w.MigrationsPath, w.SeedersPath)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			migrations = w.checkMigrations(migrations)
+			if w.Seeder != nil {
+				seeders = w.checkSeeders(seeders)
+			}
+		}
+	}
+}
+
+func (w *Watcher) checkMigrations(previous snapshot) snapshot {
+	current := readDir(w.MigrationsPath)
+
+	added, fixed := false, false
+	for _, name := range sortedNames(current) {
+		old, existed := previous[name]
+		if !existed {
+			added = true
+			continue
+		}
+		if bytes.Equal(old, current[name]) {
+			continue
+		}
+
+		color.Cyan("\nChanged: %s", name)
+		if w.reapply(name, old) {
+			fixed = true
+		}
+	}
+
+	if added || fixed {
+		if added {
+			color.Cyan("\nNew migration detected")
+		}
+		if err := w.Migration.Migrate(w.MigrationsPath); err != nil {
+			color.Red("✗ Migration failed: %v", err)
+		}
+	}
+
+	return current
+}
+
+// reapply handles an edited migration. It reports true when the migration is
+// not applied yet, e.g. one that failed and was fixed, so it should be run.
+func (w *Watcher) reapply(name string, previous []byte) bool {
+	applied, err := w.applied(name)
+	if err != nil {
+		color.Red("✗ Failed to get migration status: %v", err)
+		return false
+	}
+	if !applied {
+		return true
+	}
+
+	last, _, err := w.Migration.LastMigration()
+	if err != nil {
+		color.Red("✗ Failed to get last migration: %v", err)
+		return false
+	}
+
+	if last != name {
+		color.Yellow("⚠ Ignoring edit: %s is not the most recently applied migration", name)
+		return false
+	}
+
+	if w.Production {
+		color.Yellow("⚠ Refusing to re-apply %s in production", name)
+		return false
+	}
+
+	if err := w.Migration.Reapply(filepath.Join(w.MigrationsPath, name), previous); err != nil {
+		color.Red("✗ Re-apply failed: %v", err)
+	}
+	return false
+}
+
+func (w *Watcher) applied(name string) (bool, error) {
+	statuses, err := w.Migration.Status(w.MigrationsPath)
+	if err != nil {
+		return false, err
+	}
+	for _, status := range statuses {
+		if status.Name == name {
+			return status.Migrated, nil
+		}
+	}
+	return false, nil
+}
+
+func (w *Watcher) checkSeeders(previous snapshot) snapshot {
+	current := readDir(w.SeedersPath)
+
+	added := false
+	for _, name := range sortedNames(current) {
+		old, existed := previous[name]
+		if !existed {
+			added = true
+			continue
+		}
+		if bytes.Equal(old, current[name]) {
+			continue
+		}
+
+		color.Cyan("\nChanged: %s", name)
+		if err := w.Seeder.RunFile(filepath.Join(w.SeedersPath, name)); err != nil {
+			color.Red("✗ Seeding failed: %v", err)
+		}
+	}
+
+	if added {
+		color.Cyan("\nNew seeder detected")
+		if err := w.Seeder.RunWithTracking(w.SeedersPath); err != nil {
+			color.Red("✗ Seeding failed: %v", err)
+		}
+	}
+
+	return current
+}
+
+// readDir loads every SQL file in path. Hidden and .go files are skipped, the
+// same as the migration and seeder runners do.
+func readDir(path string) snapshot {
+	files := make(snapshot)
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return files
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".go") {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			continue
+		}
+		files[name] = content
+	}
+
+	return files
+}
+
+func sortedNames(files snapshot) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}